@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subcommands, each with a flag set of its own. Without one of them the
+// arguments are parsed as the flags of run, including the flags of the older
+// flat command line such as -filter-urls and -doctor.
+const (
+	commandRun      = "run"
+	commandFilter   = "filter"
+	commandSweep    = "sweep"
+	commandValidate = "validate"
+	commandDoctor   = "doctor"
+)
+
+// One-line descriptions of the subcommands, in the order listed in the usage
+var commandSummaries = [][2]string{
+	{commandRun, "Generate traffic (the default)"},
+	{commandFilter, "Remove unreachable URLs from the URL file"},
+	{commandSweep, "Generate traffic with a growing number of users, reporting each step"},
+	{commandValidate, "Check the configuration and URL file, then exit"},
+	{commandDoctor, "Check the environment and configuration, then exit"},
+}
+
+// options holds the values of the command line flags
+type options struct {
+	configFile       string
+	baselineRecord   string
+	baselineCompare  string
+	users            int
+	rps              int
+	urlFile          string
+	createSample     bool
+	filterURLs       bool
+	filterTimeout    int
+	filterWorkers    int
+	filterOutput     string
+	skipReachability bool
+	filterSortScore  bool
+	filterMinScore   float64
+	filterOnly       bool
+	filterForce      bool
+	allowProtocols   string
+	ipStart          string
+	ipEnd            string
+	proxyList        string
+	shardIndex       int
+	shardCount       int
+	apiAddr          string
+	logSampleRate    int
+	resultsFile      string
+	doctor           bool
+	tui              bool
+	histogram        bool
+	statsdAddr       string
+	strict           bool
+	statsFirstDelay  time.Duration
+	maxLifetime      time.Duration
+	sweepUsers       string
+	sweepStep        time.Duration
+}
+
+// defaultOptions returns the options in effect for the flags not given
+func defaultOptions() *options {
+	return &options{
+		users:           10,
+		rps:             50,
+		urlFile:         "urls/urls.txt",
+		ipStart:         "192.168.1.1",
+		ipEnd:           "192.168.1.254",
+		filterTimeout:   5,
+		filterWorkers:   20,
+		allowProtocols:  "http,https",
+		statsFirstDelay: time.Second,
+		sweepUsers:      "10,20,50,100",
+		sweepStep:       30 * time.Second,
+	}
+}
+
+// parseArgs parses the command line arguments following the program name
+// into the subcommand to run and its options
+func parseArgs(args []string, output io.Writer) (string, *options, error) {
+	command := commandRun
+	legacy := true
+	if len(args) > 0 && isCommand(args[0]) {
+		command, args, legacy = args[0], args[1:], false
+	}
+
+	opts := defaultOptions()
+	flags := newFlagSet(command, legacy, opts, output)
+	if err := flags.Parse(args); err != nil {
+		return "", nil, err
+	}
+	if flags.NArg() > 0 {
+		err := fmt.Errorf("unexpected argument %q", flags.Arg(0))
+		fmt.Fprintln(output, err)
+		flags.Usage()
+		return "", nil, err
+	}
+	if command == commandSweep {
+		if _, err := opts.sweepSteps(); err != nil {
+			fmt.Fprintln(output, err)
+			flags.Usage()
+			return "", nil, err
+		}
+	}
+	return command, opts, nil
+}
+
+// isCommand reports whether arg names a subcommand
+func isCommand(arg string) bool {
+	return slices.ContainsFunc(commandSummaries, func(summary [2]string) bool { return summary[0] == arg })
+}
+
+// newFlagSet returns the flag set of a subcommand, storing the values in opts.
+// The legacy flag set of the flat command line is run's with the flags of the
+// other subcommands added.
+func newFlagSet(command string, legacy bool, opts *options, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(output)
+
+	switch command {
+	case commandRun:
+		addConfigFlags(flags, opts)
+		addRunFlags(flags, opts)
+		if legacy {
+			flags.BoolVar(&opts.filterURLs, "filter-urls", false, "Filter URLs to remove unreachable ones")
+			flags.BoolVar(&opts.filterOnly, "filter-only", false, "Only filter URLs without starting traffic generation")
+			flags.BoolVar(&opts.doctor, "doctor", false, "Check the environment and configuration, then exit")
+			addFilterFlags(flags, opts)
+		}
+	case commandFilter:
+		flags.StringVar(&opts.configFile, "config", "", "Path to configuration file")
+		flags.StringVar(&opts.urlFile, "urls", opts.urlFile, "Path to URL list file")
+		flags.BoolVar(&opts.strict, "strict", false, "Exit with an error instead of continuing after a warning")
+		addFilterFlags(flags, opts)
+	case commandSweep:
+		addConfigFlags(flags, opts)
+		flags.StringVar(&opts.sweepUsers, "sweep-users", opts.sweepUsers, "Comma-separated numbers of concurrent users of the steps, in order; overrides -users")
+		flags.DurationVar(&opts.sweepStep, "sweep-step", opts.sweepStep, "Duration of each step")
+	case commandValidate, commandDoctor:
+		addConfigFlags(flags, opts)
+	}
+
+	flags.Usage = func() {
+		if legacy {
+			fmt.Fprintf(output, "Usage: fake-traffic-go [command] [flags]\n\nCommands:\n")
+			for _, summary := range commandSummaries {
+				fmt.Fprintf(output, "  %-10s %s\n", summary[0], summary[1])
+			}
+			fmt.Fprintf(output, "\nFlags:\n")
+		} else {
+			fmt.Fprintf(output, "Usage: fake-traffic-go %s [flags]\n\nFlags:\n", command)
+		}
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// addConfigFlags adds the flags loading the configuration and overriding its values
+func addConfigFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.configFile, "config", "", "Path to configuration file")
+	flags.StringVar(&opts.baselineRecord, "baseline-record", "", "Save per-URL statuses and latencies to this file when stopping")
+	flags.StringVar(&opts.baselineCompare, "baseline-compare", "", "Compare the run against this baseline file when stopping")
+	flags.IntVar(&opts.users, "users", opts.users, "Number of concurrent users")
+	flags.IntVar(&opts.rps, "rps", opts.rps, "Target requests per second")
+	flags.StringVar(&opts.urlFile, "urls", opts.urlFile, "Path to URL list file")
+	flags.StringVar(&opts.ipStart, "ip-start", opts.ipStart, "Start of IP range")
+	flags.StringVar(&opts.ipEnd, "ip-end", opts.ipEnd, "End of IP range")
+	flags.StringVar(&opts.proxyList, "proxy-list", "", "File listing proxy URLs to distribute among users")
+	flags.IntVar(&opts.shardIndex, "shard-index", 0, "Index of the URL shard handled by this instance")
+	flags.IntVar(&opts.shardCount, "shard-count", 0, "Total number of URL shards across instances (0 disables sharding)")
+	flags.IntVar(&opts.logSampleRate, "log-sample-rate", 0, "Log only one in N per-request events; errors are always logged (0 logs all)")
+	flags.StringVar(&opts.resultsFile, "results-file", "", "Append per-request results to this file as JSON lines")
+	flags.BoolVar(&opts.strict, "strict", false, "Exit with an error instead of continuing after a warning")
+}
+
+// addRunFlags adds the flags controlling a traffic generation run
+func addRunFlags(flags *flag.FlagSet, opts *options) {
+	flags.BoolVar(&opts.createSample, "create-sample", false, "Create a sample URL file if none exists")
+	flags.StringVar(&opts.apiAddr, "api-addr", "", "Address to serve the control API on, e.g. localhost:8080 (disabled if empty)")
+	flags.BoolVar(&opts.tui, "tui", false, "Show a live dashboard instead of printing statistics as JSON")
+	flags.BoolVar(&opts.histogram, "histogram", false, "Print a histogram of response times when stopping")
+	flags.StringVar(&opts.statsdAddr, "statsd-addr", "", "Send statistics to the StatsD server at this address, e.g. localhost:8125, along with printing them (disabled if empty)")
+	flags.DurationVar(&opts.statsFirstDelay, "stats-first-delay", opts.statsFirstDelay, "Delay before the first statistics are printed, later ones follow every 5s")
+	flags.DurationVar(&opts.maxLifetime, "max-lifetime", 0, "Hard limit on process lifetime, force-exits if shutdown hangs (0 disables)")
+}
+
+// addFilterFlags adds the flags controlling URL filtering
+func addFilterFlags(flags *flag.FlagSet, opts *options) {
+	flags.IntVar(&opts.filterTimeout, "filter-timeout", opts.filterTimeout, "Timeout in seconds when checking URL reachability")
+	flags.IntVar(&opts.filterWorkers, "filter-workers", opts.filterWorkers, "Number of concurrent workers for URL filtering")
+	flags.StringVar(&opts.filterOutput, "filter-output", "", "Output file for filtered URLs (defaults to overwriting input file)")
+	flags.BoolVar(&opts.skipReachability, "skip-reachability", false, "Skip checking if URLs are reachable (faster but less accurate)")
+	flags.BoolVar(&opts.filterSortScore, "filter-sort-score", false, "Sort filtered URLs by quality score (latency, status, redirects)")
+	flags.Float64Var(&opts.filterMinScore, "filter-min-score", 0, "Drop URLs scoring below this value (0-1) when sorting by score")
+	flags.BoolVar(&opts.filterForce, "force", false, "Overwrite the URL file when filtering even if no URL is valid")
+	flags.StringVar(&opts.allowProtocols, "allow-protocols", opts.allowProtocols, "Comma-separated URL schemes kept when filtering; schemes other than http and https are checked for syntax only (empty allows any)")
+}
+
+// protocols returns the schemes listed in -allow-protocols, lower-cased
+func (o *options) protocols() []string {
+	var protocols []string
+	for _, protocol := range strings.Split(o.allowProtocols, ",") {
+		if protocol = strings.ToLower(strings.TrimSpace(protocol)); protocol != "" {
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols
+}
+
+// sweepSteps returns the numbers of users listed in -sweep-users
+func (o *options) sweepSteps() ([]int, error) {
+	if o.sweepStep <= 0 {
+		return nil, fmt.Errorf("invalid -sweep-step %s, must be positive", o.sweepStep)
+	}
+	var steps []int
+	for _, field := range strings.Split(o.sweepUsers, ",") {
+		users, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || users < 1 {
+			return nil, fmt.Errorf("invalid -sweep-users entry %q, must be a positive number", field)
+		}
+		steps = append(steps, users)
+	}
+	return steps, nil
+}
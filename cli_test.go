@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllowProtocolsFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"filter"}, []string{"http", "https"}},
+		{[]string{"filter", "-allow-protocols", "http, HTTPS,ftp"}, []string{"http", "https", "ftp"}},
+		{[]string{"-filter-only", "-allow-protocols", "ftp"}, []string{"ftp"}},
+		{[]string{"filter", "-allow-protocols", ""}, nil},
+	}
+	for _, test := range tests {
+		_, opts, err := parseArgs(test.args, io.Discard)
+		if err != nil {
+			t.Fatalf("parseArgs(%q): %v", test.args, err)
+		}
+		if got := opts.protocols(); !slices.Equal(got, test.want) {
+			t.Errorf("parseArgs(%q) allows %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		command string
+		check   func(*options) bool
+		wantErr bool
+	}{
+		{"no arguments", nil, commandRun,
+			func(o *options) bool { return o.users == 10 && o.rps == 50 }, false},
+		{"run", []string{"run", "-users", "5", "-tui"}, commandRun,
+			func(o *options) bool { return o.users == 5 && o.tui }, false},
+		{"legacy flags", []string{"-users", "7", "-filter-urls", "-filter-workers", "3"}, commandRun,
+			func(o *options) bool { return o.users == 7 && o.filterURLs && o.filterWorkers == 3 }, false},
+		{"legacy doctor", []string{"-doctor"}, commandRun,
+			func(o *options) bool { return o.doctor }, false},
+		{"filter", []string{"filter", "-urls", "list.txt", "-skip-reachability"}, commandFilter,
+			func(o *options) bool { return o.urlFile == "list.txt" && o.skipReachability }, false},
+		{"sweep", []string{"sweep", "-sweep-users", "1,2", "-sweep-step", "1m"}, commandSweep,
+			func(o *options) bool { return o.sweepUsers == "1,2" && o.sweepStep == time.Minute }, false},
+		{"validate", []string{"validate", "-config", "config.json"}, commandValidate,
+			func(o *options) bool { return o.configFile == "config.json" }, false},
+		{"doctor", []string{"doctor", "-ip-start", "10.0.0.1"}, commandDoctor,
+			func(o *options) bool { return o.ipStart == "10.0.0.1" }, false},
+		{"unknown subcommand", []string{"serve"}, "", nil, true},
+		{"subcommand after flags", []string{"-users", "5", "filter"}, "", nil, true},
+		{"flag of another subcommand", []string{"validate", "-filter-workers", "3"}, "", nil, true},
+		{"legacy only flag with subcommand", []string{"run", "-filter-only"}, "", nil, true},
+		{"invalid sweep users", []string{"sweep", "-sweep-users", "10,x"}, "", nil, true},
+		{"non-positive sweep step", []string{"sweep", "-sweep-step", "0s"}, "", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command, opts, err := parseArgs(test.args, io.Discard)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parseArgs(%q) = %q, want an error", test.args, command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs(%q): %v", test.args, err)
+			}
+			if command != test.command {
+				t.Errorf("parseArgs(%q) command = %q, want %q", test.args, command, test.command)
+			}
+			if !test.check(opts) {
+				t.Errorf("parseArgs(%q) options = %+v", test.args, *opts)
+			}
+		})
+	}
+}
+
+func TestParseArgsHelp(t *testing.T) {
+	var output strings.Builder
+	if _, _, err := parseArgs([]string{"-h"}, &output); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("parseArgs(-h) error = %v, want flag.ErrHelp", err)
+	}
+	for _, summary := range commandSummaries {
+		if !strings.Contains(output.String(), summary[1]) {
+			t.Errorf("usage does not list the %s command:\n%s", summary[0], output.String())
+		}
+	}
+
+	output.Reset()
+	if _, _, err := parseArgs([]string{"sweep", "-h"}, &output); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("parseArgs(sweep -h) error = %v, want flag.ErrHelp", err)
+	}
+	if !strings.Contains(output.String(), "-sweep-users") || strings.Contains(output.String(), "-filter-workers") {
+		t.Errorf("sweep usage does not list exactly its flags:\n%s", output.String())
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Accepted values for HTTPVersion.Version
+var httpVersions = []string{"1.1", "2"}
+
+// HTTPVersion is a share of users speaking one HTTP version
+type HTTPVersion struct {
+	// Version the users' clients speak: "1.1" or "2"
+	Version string `json:"version"`
+
+	// Relative share of users speaking this version
+	Weight float64 `json:"weight"`
+}
+
+// validate checks a version share for unknown versions and out-of-range values
+func (v HTTPVersion) validate() error {
+	if !slices.Contains(httpVersions, v.Version) {
+		return fmt.Errorf("unknown version %q", v.Version)
+	}
+	if v.Weight <= 0 {
+		return fmt.Errorf("%q: weight must be positive", v.Version)
+	}
+	return nil
+}
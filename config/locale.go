@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// localeTag matches language tags such as "fr", "fr-FR" or "zh-Hant-TW"
+var localeTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Locale is a share of users browsing in one language
+type Locale struct {
+	// Language tag of the users, e.g. "fr-FR"
+	Tag string `json:"tag"`
+
+	// Relative share of users with this locale
+	Weight float64 `json:"weight"`
+}
+
+// validate checks a locale for malformed tags and out-of-range values
+func (l Locale) validate() error {
+	if !localeTag.MatchString(l.Tag) {
+		return fmt.Errorf("invalid language tag %q", l.Tag)
+	}
+	if l.Weight <= 0 {
+		return fmt.Errorf("%q: weight must be positive", l.Tag)
+	}
+	return nil
+}
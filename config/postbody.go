@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// PostBodySize is one bucket of the POST body size distribution, such as
+// small form submissions or large uploads
+type PostBodySize struct {
+	// Relative share of POST requests with a body in this bucket
+	Weight float64 `json:"weight"`
+
+	// Range of the body size (bytes)
+	MinBytes int `json:"min_bytes"`
+	MaxBytes int `json:"max_bytes"`
+}
+
+// validate checks a bucket for out-of-range values
+func (s PostBodySize) validate() error {
+	switch {
+	case s.Weight <= 0:
+		return fmt.Errorf("%d-%d bytes: weight must be positive", s.MinBytes, s.MaxBytes)
+	case s.MinBytes < 0 || s.MaxBytes < s.MinBytes:
+		return fmt.Errorf("%d-%d bytes: size range is invalid", s.MinBytes, s.MaxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+// EntryReferrer is a possible origin of new sessions, such as a search engine
+type EntryReferrer struct {
+	// Referer sent with the first request of a session (empty for direct traffic)
+	URL string `json:"url"`
+
+	// Relative share of sessions coming from this referrer
+	Weight float64 `json:"weight"`
+}
+
+// validate checks a referrer for out-of-range values
+func (r EntryReferrer) validate() error {
+	if r.Weight <= 0 {
+		return fmt.Errorf("%q: weight must be positive", r.URL)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleWindow is a daily time window during which traffic is enabled
+type ScheduleWindow struct {
+	// Start and end of the window in local time as "HH:MM".
+	// A window that ends before it starts spans midnight.
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	// Days the window starts on, e.g. "mon" or "saturday" (empty means every day)
+	Weekdays []string `json:"weekdays"`
+}
+
+// InSchedule reports whether traffic should run at time t.
+// It is always true when no schedule is configured.
+func (c *Config) InSchedule(t time.Time) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.Schedule) == 0 {
+		return true
+	}
+
+	for _, window := range c.Schedule {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether t falls within the window
+func (w ScheduleWindow) contains(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	if start <= end {
+		return minute >= start && minute < end && w.onDay(today)
+	}
+
+	// The window spans midnight, so early hours belong to the previous day's window
+	return (minute >= start && w.onDay(today)) || (minute < end && w.onDay(yesterday))
+}
+
+// onDay reports whether the window applies to the given weekday
+func (w ScheduleWindow) onDay(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+
+	for _, name := range w.Weekdays {
+		if d, ok := parseWeekday(name); ok && d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that the window's times and weekdays can be parsed
+func (w ScheduleWindow) validate() error {
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	for _, name := range w.Weekdays {
+		if _, ok := parseWeekday(name); !ok {
+			return fmt.Errorf("unknown weekday %q", name)
+		}
+	}
+	return nil
+}
+
+// parseClock converts "HH:MM" to minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseWeekday accepts full or three-letter English weekday names in any case
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Accepted values for SessionDuration.Distribution
+var sessionDistributions = []string{"uniform", "exponential", "lognormal"}
+
+// SessionDuration models how long users' sessions last, heavy-tailed
+// distributions giving many short visits and a few long ones
+type SessionDuration struct {
+	// Distribution durations are drawn from: uniform, exponential or lognormal
+	Distribution string `json:"distribution"`
+
+	// Range of a uniform duration (minutes). For the other distributions they
+	// optionally bound the drawn durations (0 for no bound).
+	MinMinutes float64 `json:"min_minutes"`
+	MaxMinutes float64 `json:"max_minutes"`
+
+	// Mean of an exponential or lognormal duration (minutes)
+	MeanMinutes float64 `json:"mean_minutes"`
+
+	// Spread of a lognormal duration, the standard deviation of its logarithm (0 uses 1)
+	Sigma float64 `json:"sigma"`
+}
+
+// validate checks the distribution and its parameters
+func (s *SessionDuration) validate() error {
+	switch {
+	case !slices.Contains(sessionDistributions, s.Distribution):
+		return fmt.Errorf("unknown distribution %q", s.Distribution)
+	case s.Distribution == "uniform" && (s.MinMinutes < 0 || s.MaxMinutes <= 0 || s.MaxMinutes < s.MinMinutes):
+		return errors.New("uniform range is invalid")
+	case s.Distribution != "uniform" && s.MeanMinutes <= 0:
+		return errors.New("mean_minutes must be positive")
+	case s.MinMinutes < 0 || (s.MaxMinutes > 0 && s.MaxMinutes < s.MinMinutes):
+		return errors.New("bounds are invalid")
+	case s.Sigma < 0:
+		return errors.New("sigma must not be negative")
+	}
+	return nil
+}
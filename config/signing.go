@@ -0,0 +1,25 @@
+package config
+
+import "errors"
+
+// RequestSigning configures an HMAC signature sent with every request, for
+// targets that authenticate requests by a secret shared with the client
+type RequestSigning struct {
+	// Shared secret the signature is computed with
+	Secret string `json:"secret"`
+
+	// Header carrying the signature (empty uses X-Signature)
+	Header string `json:"header"`
+
+	// Header carrying the Unix time of signing, which is part of the signed
+	// data (empty uses X-Signature-Timestamp)
+	TimestampHeader string `json:"timestamp_header"`
+}
+
+// validate checks the signing settings for a missing secret
+func (s *RequestSigning) validate() error {
+	if s.Secret == "" {
+		return errors.New("secret must not be empty")
+	}
+	return nil
+}
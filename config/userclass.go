@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UserClass describes a share of the simulated user population, such as
+// power users with short think times and long sessions
+type UserClass struct {
+	// Name used to break down statistics by class
+	Name string `json:"name"`
+
+	// Relative share of users in this class
+	Weight float64 `json:"weight"`
+
+	// Range of the think time between page views (seconds)
+	MinThinkTime float64 `json:"min_think_time"`
+	MaxThinkTime float64 `json:"max_think_time"`
+
+	// Range of the session duration (minutes)
+	MinSessionMinutes float64 `json:"min_session_minutes"`
+	MaxSessionMinutes float64 `json:"max_session_minutes"`
+
+	// Number of requests after which a session ends (0 uses requests_per_session)
+	RequestsPerSession int `json:"requests_per_session"`
+}
+
+// PickUserClass selects a class by weighted draw, where draw is a random number
+// in [0, 1). It returns nil when no classes are configured.
+func (c *Config) PickUserClass(draw float64) *UserClass {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.UserClasses) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	for _, class := range c.UserClasses {
+		total += class.Weight
+	}
+
+	point := draw * total
+	for i := range c.UserClasses {
+		point -= c.UserClasses[i].Weight
+		if point < 0 {
+			class := c.UserClasses[i]
+			return &class
+		}
+	}
+
+	// Only reached through floating point rounding
+	class := c.UserClasses[len(c.UserClasses)-1]
+	return &class
+}
+
+// validate checks a class for missing or out-of-range values
+func (u UserClass) validate() error {
+	switch {
+	case u.Name == "":
+		return errors.New("name is required")
+	case u.Weight <= 0:
+		return fmt.Errorf("%s: weight must be positive", u.Name)
+	case u.MinThinkTime < 0 || u.MaxThinkTime < u.MinThinkTime:
+		return fmt.Errorf("%s: think time range is invalid", u.Name)
+	case u.MinSessionMinutes <= 0 || u.MaxSessionMinutes < u.MinSessionMinutes:
+		return fmt.Errorf("%s: session range is invalid", u.Name)
+	case u.RequestsPerSession < 0:
+		return fmt.Errorf("%s: requests_per_session must not be negative", u.Name)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ResponseValidation holds expectations every response must meet, so a
+// response that arrives but is wrong is told apart from one that failed
+type ResponseValidation struct {
+	// Status the response must have (0 accepts any)
+	Status int `json:"status"`
+
+	// Text the body must contain
+	BodyContains string `json:"body_contains"`
+
+	// Regular expression the body must match
+	BodyMatches string `json:"body_matches"`
+
+	// Longest time a response may take, in seconds (0 for no limit)
+	MaxLatency float64 `json:"max_latency"`
+
+	// Headers the response must have, with any value
+	RequiredHeaders []string `json:"required_headers"`
+}
+
+// validate checks the validation settings for impossible expectations
+func (v *ResponseValidation) validate() error {
+	if v.Status != 0 && (v.Status < 100 || v.Status > 599) {
+		return fmt.Errorf("status %d is not an HTTP status code", v.Status)
+	}
+	if _, err := regexp.Compile(v.BodyMatches); err != nil {
+		return fmt.Errorf("body_matches: %w", err)
+	}
+	if v.MaxLatency < 0 {
+		return errors.New("max_latency must not be negative")
+	}
+	for _, header := range v.RequiredHeaders {
+		if header == "" {
+			return errors.New("required_headers must not contain an empty name")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fake-traffic-go/internal"
+	"github.com/rivo/tview"
+)
+
+// Width of the gauge bars in characters
+const dashboardBarWidth = 40
+
+// dashboard holds the figures shown by the terminal dashboard
+type dashboard struct {
+	activeUsers int
+	targetUsers int
+	actualRPS   float64
+	targetRPS   int
+	requests    int64
+	errors      int64
+	errorRate   float64 // Share of requests since the last update that failed
+	p50         float64 // Latency percentiles in milliseconds
+	p90         float64
+	p99         float64
+}
+
+// update refreshes the dashboard from a stats snapshot. The error rate covers
+// the requests made since the previous update.
+func (d *dashboard) update(stats internal.Stats) {
+	d.errorRate = 0
+	if newRequests := counterIncrease(stats.TotalRequests, d.requests); newRequests > 0 {
+		d.errorRate = float64(counterIncrease(stats.TotalErrors, d.errors)) / float64(newRequests)
+	}
+	d.requests, d.errors = stats.TotalRequests, stats.TotalErrors
+
+	d.activeUsers, d.targetUsers = stats.ActiveUsers, stats.TargetUsers
+	d.actualRPS, d.targetRPS = stats.ActualRequestsPerSec, stats.TargetRequestsPerSec
+
+	d.p50, d.p90, d.p99 = 0, 0, 0
+	if stats.Latency != nil {
+		d.p50, d.p90, d.p99 = stats.Latency.P50, stats.Latency.P90, stats.Latency.P99
+	}
+}
+
+// gauges returns the gauge panel text, with tview color tags
+func (d *dashboard) gauges() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Users       %s %d/%d\n", bar(float64(d.activeUsers), float64(d.targetUsers), "green"), d.activeUsers, d.targetUsers)
+	fmt.Fprintf(&b, "Requests/s  %s %.1f/%d\n", bar(d.actualRPS, float64(d.targetRPS), "green"), d.actualRPS, d.targetRPS)
+	fmt.Fprintf(&b, "Error rate  %s %.1f%%", bar(d.errorRate, 1, "red"), d.errorRate*100)
+	return b.String()
+}
+
+// totals returns the latency and totals panel text
+func (d *dashboard) totals() string {
+	return fmt.Sprintf("Latency     p50 %.1fms  p90 %.1fms  p99 %.1fms\nTotal       %d requests, %d errors",
+		d.p50, d.p90, d.p99, d.requests, d.errors)
+}
+
+// bar draws a gauge filled in the color in proportion to value out of limit
+func bar(value, limit float64, color string) string {
+	filled := 0
+	if limit > 0 {
+		filled = int(min(1, max(0, value/limit)) * dashboardBarWidth)
+	}
+	return "[" + color + "]" + strings.Repeat("█", filled) + "[-]" + strings.Repeat("░", dashboardBarWidth-filled)
+}
+
+// dashboardView shows a dashboard in the terminal until stopped or Ctrl+C is pressed
+type dashboardView struct {
+	app    *tview.Application
+	gauges *tview.TextView
+	totals *tview.TextView
+	closed chan struct{} // Closed once the terminal is restored
+}
+
+// newDashboardView takes over the terminal to show the dashboard
+func newDashboardView() *dashboardView {
+	v := &dashboardView{
+		app:    tview.NewApplication(),
+		gauges: tview.NewTextView().SetDynamicColors(true),
+		totals: tview.NewTextView(),
+		closed: make(chan struct{}),
+	}
+	v.gauges.SetBorder(true).SetTitle(" Load ")
+	v.totals.SetBorder(true).SetTitle(" Responses ")
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tview.NewTextView().SetText("Fake Traffic Generator - press Ctrl+C to stop"), 1, 0, false).
+		AddItem(v.gauges, 5, 0, false).
+		AddItem(v.totals, 4, 0, false).
+		AddItem(nil, 0, 1, false)
+	v.app.SetRoot(layout, true)
+
+	go func() {
+		defer close(v.closed)
+		if err := v.app.Run(); err != nil {
+			fmt.Printf("Dashboard error: %v\n", err)
+		}
+	}()
+	return v
+}
+
+// show redraws the view with the figures of the dashboard
+func (v *dashboardView) show(d *dashboard) {
+	gauges, totals := d.gauges(), d.totals()
+	v.app.QueueUpdateDraw(func() {
+		v.gauges.SetText(gauges)
+		v.totals.SetText(totals)
+	})
+}
+
+// Closed returns a channel closed once the view is gone, as after Ctrl+C
+func (v *dashboardView) Closed() <-chan struct{} {
+	return v.closed
+}
+
+// Stop gives the terminal back
+func (v *dashboardView) Stop() {
+	// Stopping does nothing until the application has taken over the screen
+	for {
+		v.app.Stop()
+		select {
+		case <-v.closed:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"fake-traffic-go/internal"
+)
+
+func TestDashboardUpdate(t *testing.T) {
+	var d dashboard
+	d.update(internal.Stats{
+		ActiveUsers:          8,
+		TargetUsers:          10,
+		TargetRequestsPerSec: 50,
+		ActualRequestsPerSec: 42.5,
+		TotalRequests:        100,
+		TotalErrors:          10,
+		Latency:              &internal.LatencyPercentiles{P50: 12, P90: 40, P99: 95},
+	})
+	if d.activeUsers != 8 || d.targetUsers != 10 || d.actualRPS != 42.5 || d.targetRPS != 50 {
+		t.Errorf("gauges = %d/%d users, %g/%d requests/s", d.activeUsers, d.targetUsers, d.actualRPS, d.targetRPS)
+	}
+	if d.errorRate != 0.1 {
+		t.Errorf("first error rate = %g, want 0.1", d.errorRate)
+	}
+	if d.p50 != 12 || d.p90 != 40 || d.p99 != 95 {
+		t.Errorf("latency = %g/%g/%g, want 12/40/95", d.p50, d.p90, d.p99)
+	}
+
+	// The error rate covers the requests since the previous update only
+	d.update(internal.Stats{TotalRequests: 150, TotalErrors: 35})
+	if d.errorRate != 0.5 {
+		t.Errorf("error rate = %g, want 0.5 of the 50 new requests", d.errorRate)
+	}
+	if d.p50 != 0 || d.p99 != 0 {
+		t.Errorf("latency = %g/%g without responses, want 0", d.p50, d.p99)
+	}
+
+	// Totals going down after a reset count from zero
+	d.update(internal.Stats{TotalRequests: 20, TotalErrors: 1})
+	if d.errorRate != 0.05 {
+		t.Errorf("error rate after a reset = %g, want 0.05", d.errorRate)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "fake-traffic-go/internal"
+
+// Exit codes of the process
+const (
+	// exitOK is returned after a requested shutdown or a planned end of the run
+	exitOK = 0
+
+	// exitFailure is returned when the generator cannot start or shut down
+	exitFailure = 1
+
+	// exitUsage is returned for invalid command line arguments, as by flag
+	exitUsage = 2
+
+	// exitIdleTimeout is returned when the generator stopped because no request
+	// succeeded within the idle timeout
+	exitIdleTimeout = 3
+)
+
+// exitCode returns the exit code for a generator that stopped for the given reason
+func exitCode(reason internal.StopReason) int {
+	switch reason {
+	case internal.StopIdleTimeout:
+		return exitIdleTimeout
+	default:
+		return exitOK
+	}
+}
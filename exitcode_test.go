@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fake-traffic-go/internal"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		reason internal.StopReason
+		want   int
+	}{
+		{internal.StopRequested, exitOK},
+		{internal.StopPoolExhausted, exitOK},
+		{internal.StopIdleTimeout, exitIdleTimeout},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.reason); got != test.want {
+			t.Errorf("exitCode(%s) = %d, want %d", test.reason, got, test.want)
+		}
+	}
+}
+
+func TestIdleGeneratorExitsWithIdleTimeoutCode(t *testing.T) {
+	// Every request fails, so none ever succeeds
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.IdleTimeout = 1
+	cfg.IdleStop = true
+	generator := startGenerator(t, cfg)
+
+	select {
+	case <-generator.SelfStopped():
+	case <-time.After(10 * time.Second):
+		t.Fatal("generator did not stop itself after the idle timeout")
+	}
+	generator.Stop()
+	if code := exitCode(generator.StopReason()); code != exitIdleTimeout {
+		t.Errorf("exit code = %d for stop reason %q, want %d", code, generator.StopReason(), exitIdleTimeout)
+	}
+}
+
+func TestRequestedStopExitsCleanly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	generator := startTestGenerator(t, server)
+
+	generator.Stop()
+	if code := exitCode(generator.StopReason()); code != exitOK {
+		t.Errorf("exit code = %d for stop reason %q, want %d", code, generator.StopReason(), exitOK)
+	}
+}
@@ -0,0 +1,90 @@
+// Package fetch reads files given either as local paths or as http(s) URLs,
+// so configuration and URL lists can be served from a central location.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrFetchFailed is returned when a remote file cannot be fetched
+var ErrFetchFailed = errors.New("fetch failed")
+
+// TokenEnv names the environment variable holding a bearer token sent when
+// fetching remote files
+const TokenEnv = "FAKE_TRAFFIC_FETCH_TOKEN"
+
+// Time allowed for fetching a remote file, including reading its contents
+const Timeout = 30 * time.Second
+
+// IsRemote reports whether location is an http or https URL rather than a local path
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// Open opens the file at location, a local path or an http(s) URL. Remote files
+// are fetched with a GET request that must be answered with 200 OK within the
+// timeout. Credentials in the URL are sent as basic authentication, otherwise
+// a token in the TokenEnv environment variable is sent as a bearer token.
+func Open(location string) (io.ReadCloser, error) {
+	if !IsRemote(location) {
+		return os.Open(location)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	// Credentials in the URL are sent as basic authentication by the client
+	if token := os.Getenv(TokenEnv); token != "" && req.URL.User == nil {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("%w: %s: %s", ErrFetchFailed, req.URL.Redacted(), resp.Status)
+	}
+	return &body{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// ReadFile reads the whole file at location, a local path or an http(s) URL, as Open does
+func ReadFile(location string) ([]byte, error) {
+	file, err := Open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil && IsRemote(location) {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	return data, err
+}
+
+// body is the body of a fetched file, releasing the request's timeout when closed
+type body struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases the request
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
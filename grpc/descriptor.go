@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ErrNoUnaryMethods is returned for a descriptor set declaring no unary method
+var ErrNoUnaryMethods = errors.New("no unary methods in descriptor set")
+
+// LoadMethods reads a FileDescriptorSet, as written by protoc with
+// --descriptor_set_out, and returns the path of every unary method of its
+// services in the form /package.Service/Method. Streaming methods are skipped,
+// as only unary calls are sent.
+func LoadMethods(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var methods []string
+	for _, file := range set.GetFile() {
+		for _, service := range file.GetService() {
+			name := service.GetName()
+			if file.GetPackage() != "" {
+				name = file.GetPackage() + "." + name
+			}
+			for _, method := range service.GetMethod() {
+				if method.GetClientStreaming() || method.GetServerStreaming() {
+					continue
+				}
+				methods = append(methods, "/"+name+"/"+method.GetName())
+			}
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoUnaryMethods, path)
+	}
+	return methods, nil
+}
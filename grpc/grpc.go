@@ -0,0 +1,74 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ContentType is the content type of gRPC requests and responses
+const ContentType = "application/grpc"
+
+// ErrCallFailed is returned for a call answered with a status other than OK
+var ErrCallFailed = errors.New("gRPC call failed")
+
+// ErrPlaintextUnsupported is returned for calls to http:// URLs, as the
+// standard library only speaks HTTP/2 over TLS
+var ErrPlaintextUnsupported = errors.New("gRPC calls require an https URL")
+
+// Length of the header preceding each message: a compression flag and the message length
+const frameHeaderLength = 5
+
+// Frame prefixes a serialized protobuf message with the gRPC message header.
+// The message is sent uncompressed.
+func Frame(message []byte) []byte {
+	frame := make([]byte, frameHeaderLength+len(message))
+	binary.BigEndian.PutUint32(frame[1:frameHeaderLength], uint32(len(message)))
+	copy(frame[frameHeaderLength:], message)
+	return frame
+}
+
+// CheckURL verifies that a URL of the form https://host/package.Service/Method
+// can be called
+func CheckURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("%w: %s", ErrPlaintextUnsupported, rawURL)
+	}
+	if service, method, ok := strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/"); !ok || service == "" || method == "" {
+		return fmt.Errorf("%s does not name a method as /package.Service/Method", rawURL)
+	}
+	return nil
+}
+
+// StatusError returns an error describing the gRPC status of a response, or
+// nil if the call succeeded. The body must have been read to the end, as the
+// status normally arrives in the trailers.
+func StatusError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: HTTP status %d", ErrCallFailed, resp.StatusCode)
+	}
+
+	// Calls failing immediately send the status in the headers only
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+
+	switch status {
+	case "0":
+		return nil
+	case "":
+		return fmt.Errorf("%w: no status in response", ErrCallFailed)
+	}
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	return fmt.Errorf("%w: status %s: %s", ErrCallFailed, status, message)
+}
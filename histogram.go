@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"fake-traffic-go/internal"
+)
+
+// Width of the longest histogram bar in characters
+const histogramBarWidth = 40
+
+// renderHistogram prints a latency histogram with one bar per bucket, from the
+// fastest to the slowest non-empty bucket, scaled to the fullest bucket
+func renderHistogram(w io.Writer, buckets []internal.HistogramBucket) {
+	first, last := -1, -1
+	var total, largest int64
+	for i, bucket := range buckets {
+		if bucket.Count == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		total += bucket.Count
+		largest = max(largest, bucket.Count)
+	}
+
+	var b strings.Builder
+	b.WriteString("Response time histogram:\n")
+	if total == 0 {
+		b.WriteString("  no successful requests\n")
+		io.WriteString(w, b.String())
+		return
+	}
+	for _, bucket := range buckets[first : last+1] {
+		bar := strings.Repeat("■", int(bucket.Count*histogramBarWidth/largest))
+		fmt.Fprintf(&b, "  %8s - %-8s %8d %5.1f%% |%s\n", bucket.Min, formatBound(bucket.Max),
+			bucket.Count, float64(bucket.Count)/float64(total)*100, bar)
+	}
+	io.WriteString(w, b.String())
+}
+
+// formatBound formats the upper bound of a histogram bucket, 0 being the
+// unbounded end of the last bucket
+func formatBound(bound time.Duration) string {
+	if bound == 0 {
+		return "∞"
+	}
+	return bound.String()
+}
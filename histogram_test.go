@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fake-traffic-go/internal"
+)
+
+func TestRenderHistogram(t *testing.T) {
+	buckets := []internal.HistogramBucket{
+		{Min: 0, Max: time.Millisecond, Count: 0},
+		{Min: time.Millisecond, Max: 2 * time.Millisecond, Count: 30},
+		{Min: 2 * time.Millisecond, Max: 4 * time.Millisecond, Count: 0},
+		{Min: 4 * time.Millisecond, Max: 8 * time.Millisecond, Count: 10},
+		{Min: 8 * time.Millisecond, Max: 0, Count: 0},
+	}
+	var out strings.Builder
+	renderHistogram(&out, buckets)
+
+	// Empty buckets before the first and after the last non-empty one are left out
+	want := "Response time histogram:\n" +
+		"       1ms - 2ms            30  75.0% |" + strings.Repeat("■", 40) + "\n" +
+		"       2ms - 4ms             0   0.0% |\n" +
+		"       4ms - 8ms            10  25.0% |" + strings.Repeat("■", 13) + "\n"
+	if out.String() != want {
+		t.Errorf("renderHistogram() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderHistogramUnboundedBucket(t *testing.T) {
+	buckets := []internal.HistogramBucket{
+		{Min: 0, Max: time.Millisecond, Count: 1},
+		{Min: time.Millisecond, Max: 0, Count: 3},
+	}
+	var out strings.Builder
+	renderHistogram(&out, buckets)
+
+	want := "Response time histogram:\n" +
+		"        0s - 1ms             1  25.0% |" + strings.Repeat("■", 13) + "\n" +
+		"       1ms - ∞               3  75.0% |" + strings.Repeat("■", 40) + "\n"
+	if out.String() != want {
+		t.Errorf("renderHistogram() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderHistogramWithoutRequests(t *testing.T) {
+	var out strings.Builder
+	renderHistogram(&out, make([]internal.HistogramBucket, 3))
+	if want := "Response time histogram:\n  no successful requests\n"; out.String() != want {
+		t.Errorf("renderHistogram() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAbandoned is returned for requests the user gave up on before the
+// response completed, simulating a visitor navigating away mid-load
+var ErrAbandoned = errors.New("request abandoned")
+
+// Longest wait before abandoning a request when no abandon delay is configured
+const defaultAbandonDelay = 500 * time.Millisecond
+
+// requestContext returns the context for the user's next request. With the
+// configured probability it is cancelled after a random delay up to the
+// abandon delay, abandoning the request if it is still in flight.
+// The returned cancel function must be called once the request is done.
+func (u *BrowserUser) requestContext() (context.Context, context.CancelFunc) {
+	if u.abandonChance <= 0 || u.rand.Float64() >= u.abandonChance {
+		return u.ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(u.ctx)
+	delay := time.Duration(u.rand.Float64() * float64(u.abandonDelay))
+	timer := time.AfterFunc(delay, func() { cancel(ErrAbandoned) })
+
+	return ctx, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
+
+// abandoned reports whether the request context was cancelled to abandon the request
+func abandoned(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrAbandoned)
+}
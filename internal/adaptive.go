@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of manager ticks between concurrency adjustments
+	adaptiveInterval = 5
+
+	// Fewest latency samples needed for an adjustment
+	adaptiveMinSamples = 20
+
+	// Most latency samples kept between adjustments
+	adaptiveMaxSamples = 10000
+
+	// Upper bound for adaptive concurrency when MaxConcurrentUsers is not set
+	defaultMaxConcurrentUsers = 1000
+)
+
+// latencyWindow collects request latencies between concurrency adjustments
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+// add records a latency, dropping it if the window is full
+func (w *latencyWindow) add(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < adaptiveMaxSamples {
+		w.samples = append(w.samples, latency)
+	}
+}
+
+// takeP99 returns the 99th percentile of the collected latencies and the
+// number of samples, and empties the window
+func (w *latencyWindow) takeP99() (time.Duration, int) {
+	w.mu.Lock()
+	samples := w.samples
+	w.samples = nil
+	w.mu.Unlock()
+
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[(len(samples)-1)*99/100], len(samples)
+}
+
+// adaptConcurrency adjusts the number of concurrent users to hold the target
+// p99 latency (AIMD): one user is added while the p99 is within the target,
+// and the count is halved when it is exceeded
+func (g *TrafficGenerator) adaptConcurrency() {
+	target := time.Duration(g.config.TargetP99Ms * float64(time.Millisecond))
+	p99, count := g.latencies.takeP99()
+	if count < adaptiveMinSamples {
+		return
+	}
+
+	maxUsers := g.config.MaxConcurrentUsers
+	if maxUsers <= 0 {
+		maxUsers = defaultMaxConcurrentUsers
+	}
+
+	current := g.config.GetConcurrentUsers()
+	next := current
+	if p99 > target {
+		next = max(1, current/2)
+	} else if current < maxUsers {
+		next = current + 1
+	}
+
+	if next != current {
+		fmt.Printf("Latency p99 %s (target %s), adjusting concurrent users to %d\n",
+			p99.Round(time.Millisecond), target, next)
+		g.config.SetConcurrentUsers(next)
+	}
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveConcurrencyStabilizesUnderLoad(t *testing.T) {
+	// Each request in flight adds 10ms to the latency of the others
+	var inflight atomic.Int64
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		time.Sleep(time.Duration(n) * 10 * time.Millisecond)
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = 1
+	cfg.MaxConcurrentUsers = 20
+	cfg.TargetP99Ms = 35
+	cfg.BurstSize = 1 << 30   // Back-to-back requests, without thinking
+	cfg.RequestsPerSecond = 0 // Only the latency limits the request rate
+	cfg.MinThinkTime = 0
+	clock := newFakeClock(time.Date(2026, time.October, 12, 9, 0, 0, 0, time.Local))
+	g := newTestGenerator(t, cfg)
+	g.SetClock(clock)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "managing users", clock.hasTicker)
+
+	// The manager adapts every adaptiveInterval ticks; users request in real
+	// time meanwhile, holding the p99 within 35ms with up to 3 of them
+	var counts []int
+	for window := 0; window < 8; window++ {
+		for tick := 0; tick < adaptiveInterval; tick++ {
+			clock.Advance(time.Second)
+			time.Sleep(80 * time.Millisecond)
+		}
+		counts = append(counts, cfg.GetConcurrentUsers())
+	}
+
+	most := 0
+	for _, n := range counts {
+		most = max(most, n)
+	}
+	if most < 3 {
+		t.Errorf("concurrency went %v, want it to grow while latency is within the target", counts)
+	}
+	if most > 4 {
+		t.Errorf("concurrency went %v, want it to back off once latency exceeds the target", counts)
+	}
+}
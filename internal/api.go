@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fake-traffic-go/urls"
+)
+
+// NewControlHandler returns an HTTP handler exposing the generator's control API:
+//
+//	GET  /stats                    current statistics
+//	POST /stats/reset              zero all accumulated statistics
+//	GET  /users                    the active users
+//	GET  /recent?n=N               the most recent request results, newest first
+//	GET  /campaigns                the campaigns of the URLs
+//	POST /campaigns/enable?name=C  select the URLs of campaign C again
+//	POST /campaigns/disable?name=C stop selecting the URLs of campaign C
+func NewControlHandler(g *TrafficGenerator) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, g.GetStats())
+	})
+
+	mux.HandleFunc("/stats/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		g.ResetStats()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, g.ActiveUsers())
+	})
+
+	mux.HandleFunc("/recent", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		n := 0
+		if value := r.URL.Query().Get("n"); value != "" {
+			var err error
+			if n, err = strconv.Atoi(value); err != nil || n < 0 {
+				http.Error(w, "invalid n", http.StatusBadRequest)
+				return
+			}
+		}
+
+		results := g.RecentResults(n)
+		if results == nil {
+			results = []Result{}
+		}
+		writeJSON(w, results)
+	})
+
+	mux.HandleFunc("/campaigns", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, g.Campaigns())
+	})
+
+	setCampaign := func(enabled bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			err := g.SetCampaignEnabled(r.URL.Query().Get("name"), enabled)
+			if errors.Is(err, urls.ErrUnknownCampaign) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	mux.HandleFunc("/campaigns/enable", setCampaign(true))
+	mux.HandleFunc("/campaigns/disable", setCampaign(false))
+
+	return mux
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"fake-traffic-go/urls"
+)
+
+func TestControlHandler(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/spring campaign=spring", server.URL+"/summer campaign=summer")
+	cfg.ConcurrentUsers = 2
+	cfg.RecentResultsSize = 5
+	cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.1", "10.0.0.1"
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(g.ActiveUsers()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("users not started within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	g.recordResult(Result{URL: server.URL + "/spring", Status: http.StatusOK})
+	handler := NewControlHandler(g)
+
+	// The requests run in order, so later ones see the effect of earlier ones
+	tests := []struct {
+		method, path string
+		wantStatus   int
+		body         any // Decoded from the response and compared with want
+		want         any
+	}{
+		{http.MethodGet, "/users", http.StatusOK,
+			&[]ActiveUser{}, &[]ActiveUser{{ID: 0, SourceIP: "10.0.0.1"}, {ID: 1, SourceIP: "10.0.0.1"}}},
+		{http.MethodPost, "/users", http.StatusMethodNotAllowed, nil, nil},
+		{http.MethodGet, "/recent?n=x", http.StatusBadRequest, nil, nil},
+		{http.MethodDelete, "/recent", http.StatusMethodNotAllowed, nil, nil},
+		{http.MethodGet, "/campaigns", http.StatusOK,
+			&[]urls.Campaign{}, &[]urls.Campaign{{Name: "spring", URLs: 1, Enabled: true}, {Name: "summer", URLs: 1, Enabled: true}}},
+		{http.MethodPost, "/campaigns", http.StatusMethodNotAllowed, nil, nil},
+		{http.MethodGet, "/campaigns/disable?name=spring", http.StatusMethodNotAllowed, nil, nil},
+		{http.MethodPost, "/campaigns/disable?name=autumn", http.StatusNotFound, nil, nil},
+		{http.MethodPost, "/campaigns/disable?name=spring", http.StatusNoContent, nil, nil},
+		{http.MethodGet, "/campaigns", http.StatusOK,
+			&[]urls.Campaign{}, &[]urls.Campaign{{Name: "spring", URLs: 1, Enabled: false}, {Name: "summer", URLs: 1, Enabled: true}}},
+		{http.MethodPost, "/campaigns/enable?name=spring", http.StatusNoContent, nil, nil},
+		{http.MethodGet, "/campaigns", http.StatusOK,
+			&[]urls.Campaign{}, &[]urls.Campaign{{Name: "spring", URLs: 1, Enabled: true}, {Name: "summer", URLs: 1, Enabled: true}}},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+		if recorder.Code != test.wantStatus {
+			t.Errorf("%s %s status = %d, want %d", test.method, test.path, recorder.Code, test.wantStatus)
+			continue
+		}
+		if test.body == nil {
+			continue
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s %s content type = %q, want application/json", test.method, test.path, contentType)
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), test.body); err != nil {
+			t.Errorf("%s %s answered %q: %v", test.method, test.path, recorder.Body, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.body, test.want) {
+			t.Errorf("%s %s = %+v, want %+v", test.method, test.path, test.body, test.want)
+		}
+	}
+
+	// Results carry the fields documented for the results file
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recent?n=1", nil))
+	var recent []map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &recent); err != nil || len(recent) != 1 {
+		t.Fatalf("GET /recent?n=1 = %q, want one result", recorder.Body)
+	}
+	for _, field := range []string{"ts", "url", "method", "status", "duration_ms", "bytes", "source_ip"} {
+		if _, ok := recent[0][field]; !ok {
+			t.Errorf("GET /recent?n=1 result %v has no %q field", recent[0], field)
+		}
+	}
+}
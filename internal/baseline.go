@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency increase, in percent of the baseline mean, flagged as a regression
+// when no threshold is configured
+const defaultLatencyRegressionPercent = 50
+
+// Baseline holds per-URL request outcomes of a run, keyed by URL
+type Baseline map[string]*URLBaseline
+
+// URLBaseline summarises the requests made to a single URL
+type URLBaseline struct {
+	Requests      int64         `json:"requests"`
+	Errors        int64         `json:"errors"`
+	StatusCounts  map[int]int64 `json:"status_counts"`
+	totalDuration time.Duration
+	MeanLatencyMs float64 `json:"mean_latency_ms"`
+}
+
+// Regression describes a URL that did worse than in the baseline
+type Regression struct {
+	URL    string
+	Reason string
+}
+
+// SaveBaseline writes a baseline to a JSON file
+func SaveBaseline(filePath string, baseline Baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// LoadBaseline reads a baseline written by SaveBaseline
+func LoadBaseline(filePath string) (Baseline, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", filePath, err)
+	}
+	return baseline, nil
+}
+
+// CompareBaseline reports the URLs of the current run that regressed against
+// the baseline: URLs failing or answering with error statuses they didn't
+// before, and URLs whose mean latency grew by more than latencyPercent.
+// URLs missing from either run are not compared.
+func CompareBaseline(baseline, current Baseline, latencyPercent float64) []Regression {
+	var regressions []Regression
+	for url, now := range current {
+		before, ok := baseline[url]
+		if !ok || now.Requests == 0 || before.Requests == 0 {
+			continue
+		}
+
+		if now.Errors > 0 && before.Errors == 0 {
+			regressions = append(regressions, Regression{url, fmt.Sprintf("%d new request errors", now.Errors)})
+		}
+
+		for status, count := range now.StatusCounts {
+			if status >= 400 && before.StatusCounts[status] == 0 {
+				regressions = append(regressions, Regression{url, fmt.Sprintf("new status %d (%d responses)", status, count)})
+			}
+		}
+
+		if before.MeanLatencyMs > 0 && now.MeanLatencyMs > before.MeanLatencyMs*(1+latencyPercent/100) {
+			regressions = append(regressions, Regression{url, fmt.Sprintf("mean latency %.1fms, was %.1fms",
+				now.MeanLatencyMs, before.MeanLatencyMs)})
+		}
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].URL != regressions[j].URL {
+			return regressions[i].URL < regressions[j].URL
+		}
+		return regressions[i].Reason < regressions[j].Reason
+	})
+	return regressions
+}
+
+// baselineRecorder collects per-URL outcomes of the current run
+type baselineRecorder struct {
+	mu   sync.Mutex
+	urls Baseline
+}
+
+// newBaselineRecorder creates an empty recorder
+func newBaselineRecorder() *baselineRecorder {
+	return &baselineRecorder{urls: make(Baseline)}
+}
+
+// record adds a request result. Requests cut short by stopping the generator
+// are left out, as they say nothing about the target.
+func (r *baselineRecorder) record(result Result) {
+	if result.Abandoned || errors.Is(result.Err, context.Canceled) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.urls[result.URL]
+	if !ok {
+		entry = &URLBaseline{StatusCounts: make(map[int]int64)}
+		r.urls[result.URL] = entry
+	}
+
+	entry.Requests++
+	if result.Err != nil {
+		entry.Errors++
+		return
+	}
+	entry.StatusCounts[result.Status]++
+	entry.totalDuration += result.Duration
+}
+
+// snapshot returns a copy of the collected outcomes with mean latencies computed
+func (r *baselineRecorder) snapshot() Baseline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	baseline := make(Baseline, len(r.urls))
+	for url, entry := range r.urls {
+		copied := *entry
+		copied.StatusCounts = make(map[int]int64, len(entry.StatusCounts))
+		for status, count := range entry.StatusCounts {
+			copied.StatusCounts[status] = count
+		}
+		if responses := entry.Requests - entry.Errors; responses > 0 {
+			copied.MeanLatencyMs = float64(entry.totalDuration.Microseconds()) / 1000 / float64(responses)
+		}
+		baseline[url] = &copied
+	}
+	return baseline
+}
+
+// finishBaseline saves the run's baseline and compares it with the reference
+// baseline, as configured, once the generator has stopped
+func (g *TrafficGenerator) finishBaseline() {
+	current := g.baseline.snapshot()
+
+	if path := g.config.BaselineRecordFile; path != "" {
+		if err := SaveBaseline(path, current); err != nil {
+			fmt.Printf("Error saving baseline: %v\n", err)
+		} else {
+			fmt.Printf("Saved baseline of %d URLs to %s\n", len(current), path)
+		}
+	}
+
+	if g.reference == nil {
+		return
+	}
+
+	threshold := g.config.LatencyRegressionPercent
+	if threshold <= 0 {
+		threshold = defaultLatencyRegressionPercent
+	}
+
+	regressions := CompareBaseline(g.reference, current, threshold)
+	if len(regressions) == 0 {
+		fmt.Println("Baseline comparison: no regressions")
+		return
+	}
+	fmt.Printf("Baseline comparison: %d regressions\n", len(regressions))
+	for _, regression := range regressions {
+		fmt.Printf("  %s: %s\n", redactURL(regression.URL), regression.Reason)
+	}
+}
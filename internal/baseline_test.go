@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBaselineRoundTrip(t *testing.T) {
+	recorder := newBaselineRecorder()
+	recorder.record(Result{URL: "https://a.example/", Status: 200, Duration: 10 * time.Millisecond})
+	recorder.record(Result{URL: "https://a.example/", Status: 404, Duration: 30 * time.Millisecond})
+	recorder.record(Result{URL: "https://a.example/", Err: errors.New("refused")})
+	recorder.record(Result{URL: "https://b.example/", Err: context.Canceled}) // Cut short by stopping
+	baseline := recorder.snapshot()
+
+	want := Baseline{"https://a.example/": {
+		Requests:      3,
+		Errors:        1,
+		StatusCounts:  map[int]int64{200: 1, 404: 1},
+		MeanLatencyMs: 20,
+	}}
+	want["https://a.example/"].totalDuration = 40 * time.Millisecond
+	if !reflect.DeepEqual(baseline, want) {
+		t.Fatalf("snapshot = %+v, want %+v", *baseline["https://a.example/"], *want["https://a.example/"])
+	}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := SaveBaseline(path, baseline); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The total duration is only kept to compute the mean
+	want["https://a.example/"].totalDuration = 0
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loaded baseline = %+v, want %+v", *loaded["https://a.example/"], *want["https://a.example/"])
+	}
+}
+
+func TestCompareBaselineFlagsRegressions(t *testing.T) {
+	baseline := Baseline{
+		"https://same.example/":   {Requests: 10, StatusCounts: map[int]int64{200: 10}, MeanLatencyMs: 100},
+		"https://slower.example/": {Requests: 10, StatusCounts: map[int]int64{200: 10}, MeanLatencyMs: 100},
+		"https://broken.example/": {Requests: 10, StatusCounts: map[int]int64{200: 9, 404: 1}, MeanLatencyMs: 100},
+	}
+	current := Baseline{
+		"https://same.example/":   {Requests: 10, StatusCounts: map[int]int64{200: 10}, MeanLatencyMs: 140},
+		"https://slower.example/": {Requests: 10, StatusCounts: map[int]int64{200: 10}, MeanLatencyMs: 160},
+		"https://broken.example/": {Requests: 10, Errors: 2, StatusCounts: map[int]int64{404: 3, 500: 5}, MeanLatencyMs: 90},
+		"https://new.example/":    {Requests: 10, Errors: 10},
+	}
+
+	got := CompareBaseline(baseline, current, 50)
+	want := []Regression{
+		{"https://broken.example/", "2 new request errors"},
+		{"https://broken.example/", "new status 500 (5 responses)"},
+		{"https://slower.example/", "mean latency 160.0ms, was 100.0ms"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompareBaseline() = %q, want %q", got, want)
+	}
+}
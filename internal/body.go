@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+)
+
+// BodyMode selects what the client does with response bodies
+type BodyMode string
+
+const (
+	// BodyDiscard closes the body unread; the size is taken from Content-Length
+	BodyDiscard BodyMode = "discard"
+
+	// BodyCount reads and discards the body, counting its bytes
+	BodyCount BodyMode = "count"
+
+	// BodyHash reads the body and records its SHA-256 hash, to detect changing content
+	BodyHash BodyMode = "hash"
+
+	// BodyCapture reads the body and keeps a bounded sample of its start for logging
+	BodyCapture BodyMode = "capture"
+)
+
+// Sample size used in capture mode when none is configured
+const defaultBodySampleSize = 512
+
+// readBody handles the response body according to the client's body mode
+// and records what it learned in the result. With a maximum read duration,
+// a body still being read after it is closed and recorded as truncated.
+// If the response rules look at the body it is read in any mode, and its
+// start is returned for them to check. The error reading the body, if any,
+// is returned too.
+func (c *HTTPClient) readBody(resp *http.Response, result *Result) ([]byte, error) {
+	var validated *sampleWriter
+	if c.rules.needsBody() {
+		validated = &sampleWriter{limit: maxValidatedBodySize}
+	}
+	// sink adds the copy kept for validation to the writer the mode reads into
+	sink := func(w io.Writer) io.Writer {
+		if validated == nil {
+			return w
+		}
+		return io.MultiWriter(w, validated)
+	}
+
+	if c.maxBodyRead > 0 && (c.bodyMode != BodyDiscard || validated != nil) {
+		timer := time.AfterFunc(c.maxBodyRead, func() { resp.Body.Close() })
+		defer func() {
+			result.Truncated = !timer.Stop()
+		}()
+	}
+
+	var err error
+	switch c.bodyMode {
+	case BodyCount:
+		result.Bytes, err = io.Copy(sink(io.Discard), resp.Body)
+	case BodyHash:
+		hash := sha256.New()
+		result.Bytes, err = io.Copy(sink(hash), resp.Body)
+		result.BodyHash = hex.EncodeToString(hash.Sum(nil))
+	case BodyCapture:
+		sample := &sampleWriter{limit: c.sampleSize}
+		result.Bytes, err = io.Copy(sink(sample), resp.Body)
+		result.BodySample = string(sample.buf)
+	default:
+		if validated != nil {
+			result.Bytes, err = io.Copy(validated, resp.Body)
+		} else if resp.ContentLength > 0 {
+			result.Bytes = resp.ContentLength
+		}
+	}
+
+	if validated == nil {
+		return nil, err
+	}
+	return validated.buf, err
+}
+
+// sampleWriter keeps the first limit bytes written to it and discards the rest
+type sampleWriter struct {
+	buf   []byte
+	limit int
+}
+
+func (w *sampleWriter) Write(p []byte) (int, error) {
+	if room := w.limit - len(w.buf); room > 0 {
+		w.buf = append(w.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
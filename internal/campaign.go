@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"fmt"
+
+	"fake-traffic-go/urls"
+)
+
+// Campaigns returns the campaigns the loaded URLs are tagged with, and whether
+// each is enabled
+func (g *TrafficGenerator) Campaigns() []urls.Campaign {
+	return g.urlManager.Campaigns()
+}
+
+// SetCampaignEnabled enables or disables the URLs of a campaign while traffic
+// is flowing, leaving the other campaigns untouched. Users stop selecting the
+// URLs of a disabled campaign from their next page view.
+func (g *TrafficGenerator) SetCampaignEnabled(name string, enabled bool) error {
+	if err := g.urlManager.SetCampaignEnabled(name, enabled); err != nil {
+		return err
+	}
+
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	fmt.Printf("Campaign %s %s\n", name, state)
+	return nil
+}
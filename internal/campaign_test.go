@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCampaignsAreCountedAndToggledIndependently(t *testing.T) {
+	// Spring's URL answers, summer's refuses every connection
+	server := newCountingServer(t, okHandler)
+	closed := httptest.NewServer(okHandler)
+	closed.Close()
+	cfg := newTestConfig(t, server.URL+"/spring campaign=spring", closed.URL+"/summer campaign=summer")
+	cfg.ConcurrentUsers = 2
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	campaign := func(name string) CampaignTotals { return g.GetStatsSnapshot().RequestsByCampaign[name] }
+	waitUntil(t, "requesting both campaigns", func() bool {
+		return campaign("spring").Requests > 0 && campaign("summer").Requests > 0
+	})
+
+	// Turning one campaign off leaves the other flowing
+	for _, name := range []string{"spring", "summer"} {
+		other := map[string]string{"spring": "summer", "summer": "spring"}[name]
+		if err := g.SetCampaignEnabled(name, false); err != nil {
+			t.Fatal(err)
+		}
+		// Let requests already under way finish
+		before := campaign(other).Requests
+		waitUntil(t, "requesting "+other, func() bool { return campaign(other).Requests > before+5 })
+		stopped := campaign(name).Requests
+		before = campaign(other).Requests
+		waitUntil(t, "requesting "+other, func() bool { return campaign(other).Requests > before+10 })
+		if n := campaign(name).Requests; n != stopped {
+			t.Errorf("%d requests to %s while disabled, want none", n-stopped, name)
+		}
+
+		if err := g.SetCampaignEnabled(name, true); err != nil {
+			t.Fatal(err)
+		}
+		waitUntil(t, "requesting "+name+" again", func() bool { return campaign(name).Requests > stopped })
+	}
+
+	spring, summer := campaign("spring"), campaign("summer")
+	if spring.Errors != 0 || summer.Errors != summer.Requests {
+		t.Errorf("spring %+v and summer %+v, want only summer's requests to fail", spring, summer)
+	}
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// churnUsers stops randomly chosen users at the configured churn rate to model
+// visitors leaving before their session ends. adjustActiveUsers replaces them
+// with fresh users, each with a new source IP and user agent.
+func (g *TrafficGenerator) churnUsers(elapsed time.Duration) {
+	g.usersMutex.Lock()
+	defer g.usersMutex.Unlock()
+
+	// Carry fractions over so low rates still recycle users now and then
+	g.churnDebt += float64(len(g.users)) * g.config.UserChurnRate * elapsed.Minutes()
+	count := min(int(g.churnDebt), len(g.users))
+	if count == 0 {
+		return
+	}
+	g.churnDebt -= float64(count)
+
+	ids := make([]int, 0, len(g.users))
+	for id := range g.users {
+		ids = append(ids, id)
+	}
+	g.seedMutex.Lock()
+	g.seedRand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	g.seedMutex.Unlock()
+
+	for _, id := range ids[:count] {
+		g.users[id].Stop()
+		delete(g.users, id)
+	}
+	g.publishUsers()
+	fmt.Printf("Recycled %d users\n", count)
+}
@@ -1,34 +1,124 @@
 package internal
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"time"
+
+	"fake-traffic-go/grpc"
 )
 
 // HTTPClient wraps an http.Client with additional functionality
 type HTTPClient struct {
 	client          *http.Client
+	transport       *http.Transport
+	shared          bool            // The transport is shared with other clients
+	http3           *http3Transport // Sends https requests over HTTP/3 when set
 	userAgent       string
-	requestCallback func() // Function to call when a request is made
+	sourceIP        string
+	spoofHeaders    []string
+	headers         map[string]string
+	deviceHints     map[string]string
+	locale          string // Language tag Accept-Language is rendered from
+	referer         string
+	validators      validatorCache // nil unless conditional requests are enabled
+	decorators      []RequestDecorator
+	signer          *Signer        // nil unless requests are signed
+	rules           *ResponseRules // nil unless responses are validated
+	bodyMode        BodyMode
+	sampleSize      int
+	maxBodyRead     time.Duration // Longest time a body is read for, 0 for no limit
+	retryStale      bool          // Retry a GET once on a pooled connection reset by the target
+	followRedirects bool
+	maxRedirects    int
+	stripCrossHost  bool
+	log             *slog.Logger // Logs request events
+	inflight        *inflightLimiter
+	upload          *uploadLimiter
+	headerRand      *rand.Rand // Randomizes header details when set
+	timeout         time.Duration
+	timeoutJitter   float64 // Fraction the timeout varies by, 0 for a fixed timeout
+	jitterRand      *rand.Rand
+	faultRate       float64 // Share of requests failed on purpose
+	faultRand       *rand.Rand
+	requestCallback func(Result) // Function to call when a request completes
 }
 
+// Headers removed from a redirected request when it leaves the original host
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Forwarded-For"}
+
+// Header carrying the spoofed source IP unless others are configured
+var defaultSpoofHeaders = []string{"X-Forwarded-For"}
+
+// Default redirect limit when following redirects
+const defaultMaxRedirects = 10
+
+// Default time limit for a request, including reading the response body
+const defaultRequestTimeout = 10 * time.Second
+
 // NewHTTPClient creates a new HTTP client with optional request callback
-func NewHTTPClient(callback func()) *HTTPClient {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		// We don't follow redirects automatically as we want to simulate
-		// user interaction for each navigation step
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	return &HTTPClient{
-		client:          client,
+func NewHTTPClient(callback func(Result)) *HTTPClient {
+	c := &HTTPClient{
+		// Each client gets its own transport so connection pools are never
+		// shared with other users or other generators in the same process
+		transport:       http.DefaultTransport.(*http.Transport).Clone(),
 		userAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+		spoofHeaders:    defaultSpoofHeaders,
+		maxRedirects:    defaultMaxRedirects,
+		locale:          DefaultLocale,
+		timeout:         defaultRequestTimeout,
+		log:             defaultRequestLogger,
 		requestCallback: callback,
 	}
+
+	// The timeout is applied through the deadline of each request's context
+	c.client = &http.Client{
+		Transport:     c.roundTripper(),
+		CheckRedirect: c.checkRedirect,
+	}
+
+	return c
+}
+
+// fresh returns a client with the same settings but a transport of its own,
+// so it shares no connections with c
+func (c *HTTPClient) fresh() *HTTPClient {
+	clone := &HTTPClient{}
+	*clone = *c
+	clone.transport = c.transport.Clone()
+	clone.shared = false
+	if c.http3 != nil {
+		clone.http3 = newHTTP3Transport(clone.transport, c.http3.dialer)
+	}
+	if c.validators != nil {
+		// A new visitor has a cold cache
+		clone.validators = make(validatorCache)
+	}
+	clone.client = &http.Client{
+		Transport:     clone.roundTripper(),
+		CheckRedirect: clone.checkRedirect,
+	}
+	return clone
+}
+
+// roundTripper returns the transport requests are sent through, failing
+// requests on purpose when fault injection is enabled
+func (c *HTTPClient) roundTripper() http.RoundTripper {
+	var transport http.RoundTripper = c.transport
+	if c.http3 != nil {
+		transport = c.http3
+	}
+	if c.faultRate <= 0 {
+		return transport
+	}
+	return &faultTransport{next: transport, rate: c.faultRate, rand: c.faultRand}
 }
 
 // SetUserAgent sets the User-Agent header for all requests
@@ -36,42 +126,458 @@ func (c *HTTPClient) SetUserAgent(userAgent string) {
 	c.userAgent = userAgent
 }
 
-// Get makes an HTTP GET request to the specified URL
-func (c *HTTPClient) Get(url string) error {
-	req, err := http.NewRequest("GET", url, nil)
+// SetSourceIP sets the spoofed source IP sent in the spoof headers
+// and reported in request results
+func (c *HTTPClient) SetSourceIP(sourceIP string) {
+	c.sourceIP = sourceIP
+}
+
+// SetSpoofHeaders sets the headers that carry the spoofed source IP, such as
+// True-Client-IP or CF-Connecting-IP. Empty restores the default X-Forwarded-For.
+func (c *HTTPClient) SetSpoofHeaders(headers []string) {
+	c.spoofHeaders = headers
+	if len(c.spoofHeaders) == 0 {
+		c.spoofHeaders = defaultSpoofHeaders
+	}
+}
+
+// setDialer makes the client open its connections through the given dialer
+func (c *HTTPClient) setDialer(dialer *Dialer) {
+	c.transport.DialContext = dialer.DialContext
+}
+
+// setTransport makes the client send its requests through a transport shared
+// with other clients, pooling connections with them. Settings made on the
+// transport, such as a proxy, apply to all clients sharing it.
+func (c *HTTPClient) setTransport(transport *http.Transport) {
+	c.transport = transport
+	c.shared = true
+	c.client.Transport = c.roundTripper()
+}
+
+// setHTTP3 makes the client send https requests over HTTP/3, falling back to
+// its TCP transport for hosts whose QUIC handshake fails. QUIC connections go
+// to the host overrides of the dialer but are not counted against its limits.
+func (c *HTTPClient) setHTTP3(dialer *Dialer) {
+	c.http3 = newHTTP3Transport(c.transport, dialer)
+	c.client.Transport = c.roundTripper()
+}
+
+// setLocalPort makes the client bind its connections to the given local port
+// when opening them through the dialer. As a port can only carry one connection
+// to a host at a time, the client is limited to one connection per host.
+func (c *HTTPClient) setLocalPort(dialer *Dialer, port int) {
+	c.transport.DialContext = dialer.dialContextFrom(port)
+	c.transport.MaxConnsPerHost = 1
+}
+
+// SetHTTPVersion makes the client speak only the given HTTP version,
+// HTTPVersion11 or HTTPVersion2. HTTP/2 is negotiated over TLS, so a client
+// set to HTTPVersion2 still speaks HTTP/1.1 to plaintext targets.
+func (c *HTTPClient) SetHTTPVersion(version string) {
+	switch version {
+	case HTTPVersion11:
+		// A non-nil empty map keeps the transport from enabling HTTP/2
+		c.transport.ForceAttemptHTTP2 = false
+		c.transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case HTTPVersion2:
+		c.transport.ForceAttemptHTTP2 = true
+		c.transport.TLSNextProto = nil
+	}
+}
+
+// SetIdleConnTimeout sets how long an idle keep-alive connection is kept in
+// the pool, 0 keeping the default of 90 seconds. A timeout shorter than the
+// target's avoids reusing connections it already closed. A shared transport
+// is left alone, its timeout is set when creating it.
+func (c *HTTPClient) SetIdleConnTimeout(timeout time.Duration) {
+	if c.shared || timeout <= 0 {
+		return
+	}
+	c.transport.IdleConnTimeout = timeout
+}
+
+// SetStaleConnectionRetry makes the client retry a GET once, on a new
+// connection, when it failed because the target reset the pooled connection it
+// was sent over. Only the retry is reported. The transport itself already
+// retries requests failing before any of the response was read.
+func (c *HTTPClient) SetStaleConnectionRetry(enabled bool) {
+	c.retryStale = enabled
+}
+
+// SetProxy sends all requests through the given proxy, except those to unix
+// domain sockets. Credentials in the proxy URL are used for proxy authentication.
+func (c *HTTPClient) SetProxy(proxyURL *url.URL) {
+	c.transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if isUnixSocketHost(req.URL.Hostname()) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// SetHeaders sets extra headers added to every request
+func (c *HTTPClient) SetHeaders(headers map[string]string) {
+	c.headers = headers
+}
+
+// SetDecorators sets the decorators applied, in order, to every request after
+// all other headers are set
+func (c *HTTPClient) SetDecorators(decorators []RequestDecorator) {
+	c.decorators = decorators
+}
+
+// setSigner makes the client sign every request just before sending it
+func (c *HTTPClient) setSigner(signer *Signer) {
+	c.signer = signer
+}
+
+// setResponseRules makes the client check every response against the rules
+func (c *HTTPClient) setResponseRules(rules *ResponseRules) {
+	c.rules = rules
+}
+
+// SetConditionalRequests makes the client remember the ETag and Last-Modified
+// validators of responses and send If-None-Match and If-Modified-Since on
+// later requests to the same URL, as a returning visitor's browser would
+func (c *HTTPClient) SetConditionalRequests(enabled bool) {
+	c.validators = nil
+	if enabled {
+		c.validators = make(validatorCache)
+	}
+}
+
+// SetReferer sets the Referer header sent with the following requests.
+// An empty referer sends none.
+func (c *HTTPClient) SetReferer(referer string) {
+	c.referer = referer
+}
+
+// SetDeviceHints sets client hint headers describing the simulated device,
+// such as Sec-CH-UA-Mobile, added to every request
+func (c *HTTPClient) SetDeviceHints(hints map[string]string) {
+	c.deviceHints = hints
+}
+
+// SetLocale sets the language tag, such as "fr-FR", the Accept-Language
+// header is rendered from
+func (c *HTTPClient) SetLocale(locale string) {
+	c.locale = locale
+}
+
+// SetHeaderRandomization makes the client vary header details such as
+// Accept-Language quality values using the given random source.
+// A nil source sends identical headers on every request.
+func (c *HTTPClient) SetHeaderRandomization(r *rand.Rand) {
+	c.headerRand = r
+}
+
+// SetTimeoutJitter varies the timeout of every request randomly by up to
+// percent in either direction, so that users facing a stalled target don't all
+// time out, and retry, at the same moment. A percent of 0 restores a fixed timeout.
+func (c *HTTPClient) SetTimeoutJitter(percent float64, r *rand.Rand) {
+	c.timeoutJitter = percent / 100
+	c.jitterRand = r
+}
+
+// SetFaultInjection makes the client fail the given share of requests with a
+// connection error, without sending them, using the given random source.
+// A rate of 0 disables fault injection.
+func (c *HTTPClient) SetFaultInjection(rate float64, r *rand.Rand) {
+	c.faultRate = rate
+	c.faultRand = r
+	c.client.Transport = c.roundTripper()
+}
+
+// SetRedirectPolicy controls redirect handling. By default redirects are not
+// followed, as we want to simulate user interaction for each navigation step.
+// When following, at most maxRedirects are followed (0 uses the default), and
+// stripCrossHost removes sensitive headers, including the spoofed source IP,
+// from requests redirected to a different host.
+func (c *HTTPClient) SetRedirectPolicy(follow bool, maxRedirects int, stripCrossHost bool) {
+	c.followRedirects = follow
+	c.maxRedirects = maxRedirects
+	if c.maxRedirects <= 0 {
+		c.maxRedirects = defaultMaxRedirects
+	}
+	c.stripCrossHost = stripCrossHost
+}
+
+// checkRedirect applies the redirect policy to a redirected request
+func (c *HTTPClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !c.followRedirects || len(via) >= c.maxRedirects {
+		return http.ErrUseLastResponse
+	}
+
+	if c.stripCrossHost && req.URL.Host != via[0].URL.Host {
+		for _, header := range sensitiveHeaders {
+			req.Header.Del(header)
+		}
+		for _, header := range c.spoofHeaders {
+			req.Header.Del(header)
+		}
+	}
+
+	return nil
+}
+
+// setInflightLimiter makes the client wait for a slot of the limiter before
+// every request, capping the requests in flight across all clients sharing it
+func (c *HTTPClient) setInflightLimiter(limiter *inflightLimiter) {
+	c.inflight = limiter
+}
+
+// setUploadLimiter caps the rate at which request bodies are sent, across all
+// clients sharing the limiter
+func (c *HTTPClient) setUploadLimiter(limiter *uploadLimiter) {
+	c.upload = limiter
+}
+
+// setLogger sets the logger of request events, which may thin them out
+func (c *HTTPClient) setLogger(logger *slog.Logger) {
+	c.log = logger
+}
+
+// SetBodyMode controls what happens to response bodies. Any mode other than
+// BodyDiscard reads bodies to completion, which allows the underlying keep-alive
+// connection to be reused. sampleSize bounds the sample kept by BodyCapture
+// (0 uses the default).
+func (c *HTTPClient) SetBodyMode(mode BodyMode, sampleSize int) {
+	c.bodyMode = mode
+	c.sampleSize = sampleSize
+	if c.sampleSize <= 0 {
+		c.sampleSize = defaultBodySampleSize
+	}
+}
+
+// SetMaxBodyReadDuration limits the time spent reading each response body, so
+// that streamed bodies that never end don't hold up the user. 0 removes the limit.
+func (c *HTTPClient) SetMaxBodyReadDuration(d time.Duration) {
+	c.maxBodyRead = d
+}
+
+// CloseIdleConnections closes any keep-alive connections that are not in use.
+// A shared transport is left alone, its idle connections are kept for other clients.
+func (c *HTTPClient) CloseIdleConnections() {
+	if c.shared {
+		return
+	}
+	c.client.CloseIdleConnections()
+}
+
+// Get makes an HTTP GET request to the specified URL and returns its result.
+// The request is aborted if the context is cancelled.
+func (c *HTTPClient) Get(ctx context.Context, url string) (Result, error) {
+	return c.do(ctx, http.MethodGet, url, "", nil)
+}
+
+// Post makes an HTTP POST request sending body to the specified URL and
+// returns its result. The request is aborted if the context is cancelled.
+func (c *HTTPClient) Post(ctx context.Context, url string, contentType string, body []byte) (Result, error) {
+	return c.do(ctx, http.MethodPost, url, contentType, body)
+}
+
+// do makes a request with the given method and optional body
+func (c *HTTPClient) do(ctx context.Context, method string, url string, contentType string, body []byte) (Result, error) {
+	// A deadline set by the caller, such as a per-URL timeout, replaces the client's
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.timeout
+		if c.timeoutJitter > 0 {
+			factor := 1 + c.timeoutJitter*(2*c.jitterRand.Float64()-1)
+			timeout = time.Duration(float64(c.timeout) * factor)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Requests to a unix domain socket go to a placeholder host the dialer recognizes
+	requestURL, unixSocket, err := unixSocketRequestURL(url)
+	if err != nil {
+		return Result{}, fmt.Errorf("error creating request: %w", err)
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = c.upload.reader(ctx, bytes.NewReader(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, bodyReader)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return Result{}, fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil && c.upload != nil {
+		// The length is no longer known from the throttled reader
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(c.upload.reader(ctx, bytes.NewReader(body))), nil
+		}
+	}
+	if unixSocket {
+		req.Host = unixSocketHostHeader
+	}
+
+	// Wait for a slot under the in-flight ceiling; the wait is not part of the request
+	if err := c.inflight.acquire(ctx); err != nil {
+		if abandoned(ctx) {
+			return Result{}, ErrAbandoned
+		}
+		return Result{}, fmt.Errorf("request error: %w", err)
+	}
+	defer c.inflight.release()
+
+	result := Result{
+		Timestamp: time.Now(),
+		URL:       url,
+		Method:    req.Method,
+		SourceIP:  c.sourceIP,
+		BytesSent: int64(len(body)),
 	}
 
 	// Set common headers to make the request look realistic
 	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Upgrade-Insecure-Requests", "1")
-	req.Header.Set("Cache-Control", "max-age=0")
+	if c.headerRand != nil {
+		setRandomizedHeaders(req.Header, c.locale, c.headerRand)
+	} else {
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+		req.Header.Set("Accept-Language", acceptLanguage(c.locale, nil))
+		req.Header.Set("Upgrade-Insecure-Requests", "1")
+		req.Header.Set("Cache-Control", "max-age=0")
+	}
+	if c.sourceIP != "" {
+		for _, header := range c.spoofHeaders {
+			req.Header.Set(header, c.sourceIP)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.referer != "" {
+		req.Header.Set("Referer", c.referer)
+	}
+	if contentType == grpc.ContentType {
+		req.Header.Set("TE", "trailers")
+	}
+	for name, value := range c.deviceHints {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	if c.validators != nil {
+		c.validators.apply(req, url)
+	}
+	for _, decorate := range c.decorators {
+		decorate(req)
+	}
+	if c.signer != nil {
+		// Signed last, as decorators may change the URL
+		c.signer.Sign(req)
+	}
 
-	resp, err := c.client.Do(req)
+	resp, responseBody, err := c.exchange(ctx, req, &result)
 	if err != nil {
-		return fmt.Errorf("request error: %w", err)
+		// A request the user navigated away from is not a failure of the target
+		if abandoned(ctx) {
+			result.Abandoned = true
+			c.report(result)
+			return result, ErrAbandoned
+		}
+
+		result.Err = err
+		c.report(result)
+		return result, fmt.Errorf("request error: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Log the response status
-	fmt.Printf("Response status: %s\n", resp.Status)
+	if c.validators != nil {
+		c.validators.store(resp, url)
+	}
+	if contentType == grpc.ContentType {
+		// The status of a call arrives in the trailers, after the body
+		io.Copy(io.Discard, resp.Body)
+	}
+	if abandoned(ctx) {
+		result.Abandoned = true
+		c.report(result)
+		return result, ErrAbandoned
+	}
 
-	// Call the request callback if provided
-	if c.requestCallback != nil {
-		c.requestCallback()
+	if contentType == grpc.ContentType {
+		if err := grpc.StatusError(resp); err != nil {
+			result.Err = err
+			c.report(result)
+			return result, fmt.Errorf("request error: %w", err)
+		}
 	}
 
-	return nil
+	// A response failing validation arrived, so it is told apart from errors
+	if c.rules != nil {
+		result.Invalid = c.rules.check(resp, result.Duration, responseBody)
+	}
+
+	// Log the response status, always when the response is invalid
+	message := "Response status: " + resp.Status
+	if result.BodySample != "" {
+		message += fmt.Sprintf("\nResponse body: %q", result.BodySample)
+	}
+	if result.Invalid != nil {
+		c.log.Warn(message + fmt.Sprintf("\nResponse invalid: %v", result.Invalid))
+	} else {
+		c.log.Info(message)
+	}
+	c.report(result)
+
+	return result, nil
 }
 
-// Post makes an HTTP POST request to the specified URL with form data
-func (c *HTTPClient) Post(url string, contentType string, body []byte) error {
-	// Implementation similar to Get but with POST method
-	// This would be used for forms and login simulations
-	// Left as an exercise or for future implementation
-	return nil
+// exchange sends the request and reads the response body into the result,
+// failing if the body could not be read in full. With
+// stale connection retries, a GET is sent once more, on a new connection, if
+// the target reset the pooled connection before the body was read; the
+// transport itself only retries requests failing before the response.
+func (c *HTTPClient) exchange(ctx context.Context, req *http.Request, result *Result) (*http.Response, []byte, error) {
+	retry := c.retryStale && req.Method == http.MethodGet
+	var reused bool
+	if retry {
+		req = traceReuse(req, &reused)
+	}
+
+	first := *result
+	for {
+		resp, err := c.client.Do(req)
+		result.Duration = time.Since(result.Timestamp)
+		if err == nil {
+			result.Status = resp.StatusCode
+			result.Proto = resp.Proto
+			body, err := c.readBody(resp, result)
+			if result.Truncated {
+				// Closed on purpose at the maximum read duration
+				err = nil
+			}
+			if !retry || !isStaleConnection(ctx, reused, err) {
+				if err != nil {
+					resp.Body.Close()
+					return nil, nil, fmt.Errorf("error reading response body: %w", err)
+				}
+				return resp, body, nil
+			}
+			resp.Body.Close()
+		} else if !retry || !isStaleConnection(ctx, reused, err) {
+			return nil, nil, err
+		}
+
+		// Only the retry is reported, as if the stale connection was never used.
+		// A GET has no body to resend, so the request can be sent again as is.
+		retry = false
+		*result = first
+		result.Timestamp = time.Now()
+	}
+}
+
+// report passes a result to the request callback if one is provided
+func (c *HTTPClient) report(result Result) {
+	if c.requestCallback != nil {
+		c.requestCallback(result)
+	}
 }
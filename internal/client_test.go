@@ -0,0 +1,387 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Size of the bodies served by the resetting server
+const resetBodySize = 100
+
+// resettingServer serves a full body for the first request on every
+// connection and resets the connection halfway through the body of the
+// second, like a target recycling a keep-alive connection while answering
+type resettingServer struct {
+	listener    net.Listener
+	connections atomic.Int64
+	open        []net.Conn
+	mu          sync.Mutex
+	wg          sync.WaitGroup
+}
+
+func newResettingServer(t *testing.T) *resettingServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &resettingServer{listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.mu.Lock()
+		for _, conn := range s.open {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *resettingServer) URL() string {
+	return "http://" + s.listener.Addr().String() + "/"
+}
+
+func (s *resettingServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.connections.Add(1)
+		s.mu.Lock()
+		s.open = append(s.open, conn)
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for i := 0; ; i++ {
+				if _, err := http.ReadRequest(reader); err != nil {
+					return
+				}
+				fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", resetBodySize)
+				if i == 0 {
+					conn.Write(make([]byte, resetBodySize))
+					continue
+				}
+				conn.Write(make([]byte, resetBodySize/2))
+				conn.(*net.TCPConn).SetLinger(0) // Close with a reset
+				return
+			}
+		}()
+	}
+}
+
+// newCountingClient returns a client reading bodies in full and the results it reported
+func newCountingClient() (*HTTPClient, *[]Result) {
+	var results []Result
+	c := NewHTTPClient(func(r Result) { results = append(results, r) })
+	c.SetBodyMode(BodyCount, 0)
+	return c, &results
+}
+
+func TestStaleConnectionResetIsRetriedTransparently(t *testing.T) {
+	srv := newResettingServer(t)
+	c, results := newCountingClient()
+	c.SetStaleConnectionRetry(true)
+
+	for i := 0; i < 2; i++ {
+		result, err := c.Get(context.Background(), srv.URL())
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if result.Bytes != resetBodySize {
+			t.Fatalf("request %d: read %d bytes, want %d", i, result.Bytes, resetBodySize)
+		}
+	}
+	if n := srv.connections.Load(); n != 2 {
+		t.Errorf("server saw %d connections, want 2: the reused one and the retry's", n)
+	}
+	if len(*results) != 2 {
+		t.Errorf("%d results reported, want 2 without the reset attempt", len(*results))
+	}
+}
+
+func TestBodyReadResetIsReportedAsError(t *testing.T) {
+	srv := newResettingServer(t)
+	c, results := newCountingClient()
+
+	if _, err := c.Get(context.Background(), srv.URL()); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.Get(context.Background(), srv.URL())
+	if err == nil {
+		t.Fatal("request reset halfway through the body succeeded")
+	}
+	if result.Err == nil || (*results)[1].Err == nil {
+		t.Error("reported result of the reset request carries no error")
+	}
+}
+
+func TestCrossHostRedirectStripsSensitiveHeaders(t *testing.T) {
+	// Both servers listen on 127.0.0.1, so only the port tells the hosts apart
+	received := make(chan http.Header, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.RedirectHandler(target.URL+"/landing", http.StatusFound))
+	defer origin.Close()
+
+	for _, strip := range []bool{true, false} {
+		c, _ := newCountingClient()
+		c.SetHeaders(map[string]string{"Authorization": "Bearer secret"})
+		c.SetSourceIP("203.0.113.7")
+		c.SetRedirectPolicy(true, 0, strip)
+
+		result, err := c.Get(context.Background(), origin.URL+"/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != http.StatusOK {
+			t.Fatalf("strip %v: status %d, want the redirect followed to 200", strip, result.Status)
+		}
+		header := <-received
+		if present := header.Get("Authorization") != ""; present == strip {
+			t.Errorf("strip %v: redirected request has Authorization %q", strip, header.Get("Authorization"))
+		}
+		if present := header.Get("X-Forwarded-For") != ""; present == strip {
+			t.Errorf("strip %v: redirected request has X-Forwarded-For %q", strip, header.Get("X-Forwarded-For"))
+		}
+	}
+}
+
+func TestRandomizedHeadersVaryAndStayValid(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer server.Close()
+
+	c, _ := newCountingClient()
+	c.SetLocale("de-DE")
+	c.SetHeaderRandomization(rand.New(rand.NewSource(1)))
+	acceptLanguage := regexp.MustCompile(`^de-DE,de;q=0\.([1-9]),en;q=0\.([1-9])$`)
+	variants := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		if _, err := c.Get(context.Background(), server.URL); err != nil {
+			t.Fatal(err)
+		}
+		header := <-received
+
+		if accept := header.Get("Accept"); !slices.Contains(acceptVariants, accept) {
+			t.Errorf("Accept %q is not a browser's", accept)
+		}
+		match := acceptLanguage.FindStringSubmatch(header.Get("Accept-Language"))
+		if match == nil || match[1] < match[2] {
+			t.Errorf("Accept-Language %q is not valid for de-DE with decreasing quality", header.Get("Accept-Language"))
+		}
+		for _, name := range []string{"Upgrade-Insecure-Requests", "DNT"} {
+			if value, ok := header[name]; ok && value[0] != "1" {
+				t.Errorf("%s is %q, want 1 when sent", name, value[0])
+			}
+		}
+
+		var key strings.Builder
+		for _, name := range []string{"Accept", "Accept-Language", "Upgrade-Insecure-Requests", "Cache-Control", "DNT"} {
+			fmt.Fprintf(&key, "%s=%q ", name, header[name])
+		}
+		variants[key.String()] = true
+	}
+	if len(variants) < 10 {
+		t.Errorf("100 requests sent %d distinct header sets, want them to vary", len(variants))
+	}
+}
+
+func TestHashBodyModeIsStableForIdenticalResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "page %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	c, _ := newCountingClient()
+	c.SetBodyMode(BodyHash, 0)
+	hash := func(path string) string {
+		result, err := c.Get(context.Background(), server.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.BodyHash
+	}
+
+	first, second, other := hash("/a"), hash("/a"), hash("/b")
+	sum := sha256.Sum256([]byte("page /a"))
+	if want := hex.EncodeToString(sum[:]); first != want {
+		t.Errorf("hash of the body = %q, want its SHA-256 %q", first, want)
+	}
+	if second != first {
+		t.Errorf("identical responses hashed to %q and %q, want the same hash", first, second)
+	}
+	if other == first {
+		t.Errorf("different responses both hashed to %q", first)
+	}
+}
+
+// timeoutSpread sends requests to a stalling server at once from clients with
+// a 200ms timeout varied by percent, and returns the earliest and latest time
+// the requests timed out after
+func timeoutSpread(t *testing.T, percent float64) (time.Duration, time.Duration) {
+	t.Helper()
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-stall:
+		}
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	r := rand.New(rand.NewSource(1))
+	durations := make([]time.Duration, 20)
+	var wg sync.WaitGroup
+	for i := range durations {
+		c, _ := newCountingClient()
+		c.timeout = 200 * time.Millisecond
+		c.SetTimeoutJitter(percent, rand.New(rand.NewSource(r.Int63())))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			if _, err := c.Get(context.Background(), server.URL); err == nil {
+				t.Error("request to a stalling server succeeded")
+			}
+			durations[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+	return slices.Min(durations), slices.Max(durations)
+}
+
+func TestTimeoutJitterSpreadsTimeouts(t *testing.T) {
+	if earliest, latest := timeoutSpread(t, 0); latest-earliest > 50*time.Millisecond {
+		t.Errorf("fixed timeouts fired from %s to %s, want them together", earliest, latest)
+	}
+
+	// ±50% spreads the timeouts between 100ms and 300ms
+	earliest, latest := timeoutSpread(t, 50)
+	if earliest < 100*time.Millisecond || latest > 350*time.Millisecond {
+		t.Errorf("jittered timeouts fired from %s to %s, want them within 100-300ms", earliest, latest)
+	}
+	if latest-earliest < 100*time.Millisecond {
+		t.Errorf("jittered timeouts fired from %s to %s, want them spread out", earliest, latest)
+	}
+}
+
+func TestRequestDecoratorsCompose(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer server.Close()
+
+	c, _ := newCountingClient()
+	c.SetDecorators([]RequestDecorator{
+		HeaderDecorator("X-Tenant", "acme"),
+		// Runs after the first, so it sees the header it set
+		func(req *http.Request) {
+			req.URL.Path = "/" + req.Header.Get("X-Tenant") + req.URL.Path
+		},
+		QueryDecorator("v", "2"),
+	})
+	result, err := c.Get(context.Background(), server.URL+"/orders?page=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-received
+	if r.Header.Get("X-Tenant") != "acme" {
+		t.Errorf("X-Tenant = %q, want acme from the header decorator", r.Header.Get("X-Tenant"))
+	}
+	if got := r.URL.RequestURI(); got != "/acme/orders?page=1&v=2" {
+		t.Errorf("request URI = %q, want /acme/orders?page=1&v=2 from the decorators in order", got)
+	}
+	if result.URL != server.URL+"/orders?page=1" {
+		t.Errorf("result URL = %q, want the undecorated URL", result.URL)
+	}
+}
+
+func TestUploadLimitCapsThroughput(t *testing.T) {
+	const limit, size = 100_000, 50_000 // Bytes per second and per body
+	consumed := make(chan int64, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		consumed <- n
+	}))
+	defer server.Close()
+
+	c, _ := newCountingClient()
+	c.setUploadLimiter(newUploadLimiter(limit))
+	start := time.Now()
+	if _, err := c.Post(context.Background(), server.URL, "application/octet-stream", make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	if n := <-consumed; n != size {
+		t.Fatalf("server consumed %d bytes, want %d", n, size)
+	}
+	if throughput := float64(size) / elapsed.Seconds(); throughput > limit*1.1 {
+		t.Errorf("uploaded %d bytes in %s (%.0f B/s), want at most %d B/s", size, elapsed, throughput, limit)
+	}
+}
+
+func TestMaxBodyReadDurationStopsStreamingBody(t *testing.T) {
+	const event = "data: tick\n\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			fmt.Fprint(w, event)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}))
+	defer server.Close()
+
+	c, _ := newCountingClient()
+	c.SetMaxBodyReadDuration(200 * time.Millisecond)
+	start := time.Now()
+	result, err := c.Get(context.Background(), server.URL)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("reading a stream: %v, want it closed without an error", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("reading a stream took %s, want it stopped after 200ms", elapsed)
+	}
+	if !result.Truncated || result.Bytes < 5*int64(len(event)) {
+		t.Errorf("result truncated %v with %d bytes, want the partial body of about 10 events recorded", result.Truncated, result.Bytes)
+	}
+}
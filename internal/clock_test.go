@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fake-traffic-go/config"
+)
+
+// fakeClock is a Clock whose time only moves when the test advances it
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	tickers []*fakeTicker
+}
+
+// fakeWaiter is a pending After call
+type fakeWaiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), c: ch})
+	return ch
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ticker := &fakeTicker{c: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward, firing the After calls that are due and
+// delivering one tick to every ticker
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		waiter.c <- c.now
+	}
+	c.waiters = pending
+
+	for _, ticker := range c.tickers {
+		select {
+		case ticker.c <- c.now:
+		default: // The previous tick is still unread, like time.Ticker
+		}
+	}
+}
+
+// hasTicker reports whether a ticker was created, so the goroutine of its
+// owner is ready for the ticks of Advance
+func (c *fakeClock) hasTicker() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tickers) > 0
+}
+
+// fakeTicker is a Ticker driven by a fakeClock
+type fakeTicker struct {
+	c chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {}
+
+// waitUntil polls cond until it holds, failing the test after 5s
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("not %s within 5s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestScheduleDisablesTrafficOutsideWindow(t *testing.T) {
+	var hits atomic.Int64
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = 1
+	cfg.Schedule = []config.ScheduleWindow{{Start: "09:00", End: "17:00"}}
+	clock := newFakeClock(time.Date(2026, time.October, 12, 20, 0, 0, 0, time.Local))
+	g := newTestGenerator(t, cfg)
+	g.SetClock(clock)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Evening: the first tick finds the generator outside its window
+	waitUntil(t, "managing users", clock.hasTicker)
+	clock.Advance(time.Second)
+	waitUntil(t, "disabled outside the window", func() bool { return !cfg.IsEnabled() })
+	if n := len(g.ActiveUsers()); n != 0 {
+		t.Errorf("%d users active outside the window, want 0", n)
+	}
+
+	// Next morning: traffic runs
+	clock.Advance(13*time.Hour + 30*time.Minute)
+	waitUntil(t, "enabled within the window", cfg.IsEnabled)
+	waitUntil(t, "requesting within the window", func() bool { return hits.Load() > 0 })
+
+	// After hours: traffic pauses again
+	clock.Advance(8 * time.Hour)
+	waitUntil(t, "disabled after the window", func() bool { return !cfg.IsEnabled() })
+	waitUntil(t, "without users after the window", func() bool { return len(g.ActiveUsers()) == 0 })
+}
+
+func TestFakeClockExpiresSessionsInstantly(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = 1
+	clock := newFakeClock(time.Date(2026, time.October, 12, 9, 0, 0, 0, time.Local))
+	g := newTestGenerator(t, cfg)
+	g.SetClock(clock)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "managing users", clock.hasTicker)
+	clock.Advance(time.Second)
+	waitForRequests(t, g, 1)
+	if users := g.ActiveUsers(); len(users) != 1 || users[0].ID != 0 {
+		t.Fatalf("active users %+v, want the first user", users)
+	}
+
+	// Sessions last at most 30 minutes, so after an hour the first user is
+	// gone and the next tick replaces it
+	start := time.Now()
+	clock.Advance(time.Hour)
+	waitUntil(t, "replaced after its session", func() bool {
+		clock.Advance(time.Second)
+		users := g.ActiveUsers()
+		return len(users) == 1 && users[0].ID != 0
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("an hour-long session took %s to expire, want it driven by the fake clock", elapsed)
+	}
+}
+
+func TestUserChurnReplacesUsers(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = 5
+	cfg.UserChurnRate = 30 // Half of the users every second
+	cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.0", "10.255.255.255"
+	clock := newFakeClock(time.Date(2026, time.October, 12, 9, 0, 0, 0, time.Local))
+	g := newTestGenerator(t, cfg)
+	g.SetClock(clock)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "managing users", clock.hasTicker)
+	clock.Advance(time.Second)
+	waitUntil(t, "starting 5 users", func() bool { return len(g.ActiveUsers()) == 5 })
+	first := make(map[string]bool)
+	for _, user := range g.ActiveUsers() {
+		first[user.SourceIP] = true
+	}
+
+	// Long-lived sessions only end by churn, which keeps the count steady
+	ids, ips := make(map[int]bool), make(map[string]bool)
+	for tick := 0; tick < 10; tick++ {
+		clock.Advance(time.Second)
+		time.Sleep(10 * time.Millisecond)
+		var users []ActiveUser
+		waitUntil(t, "replacing churned users", func() bool {
+			users = g.ActiveUsers()
+			return len(users) == 5
+		})
+		for _, user := range users {
+			ids[user.ID] = true
+			ips[user.SourceIP] = true
+		}
+	}
+	newIPs := 0
+	for ip := range ips {
+		if !first[ip] {
+			newIPs++
+		}
+	}
+	if len(ids) < 15 || newIPs < 10 {
+		t.Errorf("10s of churn showed %d users and %d new source IPs, want users recycled with new IPs", len(ids), newIPs)
+	}
+}
@@ -0,0 +1,23 @@
+package internal
+
+import "net/http"
+
+// RequestDecorator modifies an outgoing request before it is sent, for example
+// to add headers or rewrite the URL. Results keep reporting the original URL.
+type RequestDecorator func(*http.Request)
+
+// HeaderDecorator returns a decorator setting a request header
+func HeaderDecorator(name, value string) RequestDecorator {
+	return func(req *http.Request) {
+		req.Header.Set(name, value)
+	}
+}
+
+// QueryDecorator returns a decorator setting a query parameter of the request URL
+func QueryDecorator(name, value string) RequestDecorator {
+	return func(req *http.Request) {
+		query := req.URL.Query()
+		query.Set(name, value)
+		req.URL.RawQuery = query.Encode()
+	}
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"strconv"
+
+	"fake-traffic-go/ipspoof"
+)
+
+// Device classes a user can present as
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+)
+
+// Ranges of the viewport width reported in client hints, in CSS pixels
+var (
+	mobileViewportWidths  = [2]int{360, 430}
+	desktopViewportWidths = [2]int{1280, 1920}
+)
+
+// assignDevice makes the user a mobile user with probability mobileRatio and
+// a desktop user otherwise, with a matching user agent and client hints.
+// A ratio of 0 leaves the user agent as is and sends no device hints.
+func (u *BrowserUser) assignDevice(mobileRatio float64) {
+	if mobileRatio <= 0 {
+		return
+	}
+
+	widths := desktopViewportWidths
+	u.Device = DeviceDesktop
+	if u.rand.Float64() < mobileRatio {
+		widths = mobileViewportWidths
+		u.Device = DeviceMobile
+		u.UserAgent = ipspoof.GenerateRandomMobileUserAgentFrom(u.rand)
+	}
+
+	width := widths[0] + u.rand.Intn(widths[1]-widths[0]+1)
+	u.client.SetDeviceHints(deviceHints(u.Device == DeviceMobile, width))
+}
+
+// deviceHints returns the client hint headers describing a device
+func deviceHints(mobile bool, viewportWidth int) map[string]string {
+	hints := map[string]string{
+		"Sec-CH-UA-Mobile":      "?0",
+		"Sec-CH-Viewport-Width": strconv.Itoa(viewportWidth),
+		"Viewport-Width":        strconv.Itoa(viewportWidth),
+	}
+	if mobile {
+		hints["Sec-CH-UA-Mobile"] = "?1"
+	}
+	return hints
+}
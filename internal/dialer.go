@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dialer opens the network connections used by the HTTP clients of a generator.
+// It can cap the number of connections open at the same time across all clients,
+// and the rate at which new connections are opened.
+type Dialer struct {
+	dialer  *net.Dialer
+	slots   chan struct{} // One entry per open connection; nil when unlimited
+	connect *RateLimiter  // Paces new connections; nil when unlimited
+	pinned  map[string]string
+	lookups chan struct{} // One entry per host name lookup in flight; nil when unlimited
+	retries int           // Retries of temporary DNS failures
+	delay   time.Duration // Wait before the first DNS retry, doubling for each further one
+}
+
+// Wait before the first retry of a temporary DNS failure unless configured otherwise
+const defaultDNSRetryDelay = 200 * time.Millisecond
+
+// NewDialer creates a dialer allowing at most maxOpen simultaneously open
+// connections. A value of 0 or less means no limit.
+func NewDialer(maxOpen int) *Dialer {
+	d := &Dialer{
+		// Same settings as the net/http default transport
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}
+	if maxOpen > 0 {
+		d.slots = make(chan struct{}, maxOpen)
+	}
+	return d
+}
+
+// SetConnectRate limits how many new connections are opened per second, which
+// smooths the connection ramp when many users start at once. A rate of 0 or
+// less means no limit. It must be called before the dialer is used.
+func (d *Dialer) SetConnectRate(perSecond int) {
+	d.connect = nil
+	if perSecond > 0 {
+		d.connect = NewRateLimiter(func() int { return perSecond })
+	}
+}
+
+// SetHostOverrides makes connections to the given hosts go to the mapped IP
+// addresses instead of resolving the host names. As only the dialed address
+// changes, the Host header and TLS server name keep the original host.
+// It must be called before the dialer is used.
+func (d *Dialer) SetHostOverrides(overrides map[string]string) {
+	d.pinned = make(map[string]string, len(overrides))
+	for host, ip := range overrides {
+		d.pinned[strings.ToLower(host)] = ip
+	}
+}
+
+// SetDNSRetries makes the dialer retry connections failing to resolve the host
+// temporarily, e.g. on a SERVFAIL answer or a timeout, up to retries times. The
+// first retry waits delay (the default of 200ms if 0), each further one twice
+// as long as the previous. It must be called before the dialer is used.
+func (d *Dialer) SetDNSRetries(retries int, delay time.Duration) {
+	d.retries = retries
+	d.delay = delay
+	if d.delay <= 0 {
+		d.delay = defaultDNSRetryDelay
+	}
+}
+
+// SetMaxConcurrentDNS limits the host name lookups in flight at the same time
+// to limit, so a burst of connections to many hosts doesn't overwhelm the
+// resolver. With a limit the dialer resolves hosts itself and tries their
+// addresses in turn. A value of 0 or less means no limit. It must be called
+// before the dialer is used.
+func (d *Dialer) SetMaxConcurrentDNS(limit int) {
+	d.lookups = nil
+	if limit > 0 {
+		d.lookups = make(chan struct{}, limit)
+	}
+}
+
+// DialContext connects to the address, first waiting for a free connection
+// slot if the number of open connections is capped. The slot is released
+// when the returned connection is closed.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.dial(ctx, d.dialer, network, addr)
+}
+
+// dialContextFrom returns a dial function like DialContext whose connections
+// are bound to the given local port
+func (d *Dialer) dialContextFrom(port int) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := *d.dialer
+	dialer.LocalAddr = &net.TCPAddr{Port: port}
+	dialer.Control = setReuseAddr
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.dial(ctx, &dialer, network, addr)
+	}
+}
+
+// pinnedAddr returns the address connections to addr go to, which differs
+// from addr if its host is overridden
+func (d *Dialer) pinnedAddr(addr string) string {
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		if ip, ok := d.pinned[strings.ToLower(host)]; ok {
+			return net.JoinHostPort(ip, port)
+		}
+	}
+	return addr
+}
+
+// dial connects using the given dialer, honouring the connection rate and cap.
+// Addresses standing for a unix domain socket are dialed as such.
+func (d *Dialer) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	if path, ok := unixSocketPath(addr); ok {
+		// Local port binding does not apply to sockets
+		dialer, network, addr = d.dialer, "unix", path
+	} else {
+		addr = d.pinnedAddr(addr)
+	}
+
+	if d.connect != nil {
+		if err := d.connect.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.slots == nil {
+		return d.dialRetrying(ctx, dialer, network, addr)
+	}
+
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	conn, err := d.dialRetrying(ctx, dialer, network, addr)
+	if err != nil {
+		<-d.slots
+		return nil, err
+	}
+
+	return &limitedConn{Conn: conn, release: func() { <-d.slots }}, nil
+}
+
+// dialRetrying dials with the given dialer, retrying temporary DNS failures as
+// configured so they don't count as failed requests unless they persist
+func (d *Dialer) dialRetrying(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	delay := d.delay
+	for attempt := 0; ; attempt++ {
+		conn, err := d.dialHost(ctx, dialer, network, addr)
+		if err == nil || attempt >= d.retries || !isTemporaryDNSError(err) {
+			return conn, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// dialHost dials with the given dialer, resolving the host first under the
+// lookup limit if there is one
+func (d *Dialer) dialHost(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if d.lookups == nil || err != nil || !strings.HasPrefix(network, "tcp") || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := d.lookup(ctx, dialer.Resolver, "ip"+strings.TrimPrefix(network, "tcp"), host)
+	if err != nil {
+		// Reported like a lookup failing within the dialer
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	for i, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil || i == len(ips)-1 || ctx.Err() != nil {
+			return conn, err
+		}
+	}
+	return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}}
+}
+
+// lookup resolves the host to its addresses in the given network ("ip", "ip4"
+// or "ip6") once a lookup slot is free. A nil resolver uses the default one.
+func (d *Dialer) lookup(ctx context.Context, resolver *net.Resolver, network, host string) ([]netip.Addr, error) {
+	select {
+	case d.lookups <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-d.lookups }()
+
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return resolver.LookupNetIP(ctx, network, host)
+}
+
+// isTemporaryDNSError reports whether err is a failure to resolve a host that
+// may succeed when tried again
+func isTemporaryDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+}
+
+// limitedConn releases its connection slot when closed
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close closes the connection and frees its slot
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingResolver is a resolver whose DNS queries all fail after a while,
+// recording how many were in flight at most
+type countingResolver struct {
+	inflight atomic.Int64
+	most     atomic.Int64
+	queries  atomic.Int64
+}
+
+// resolver returns a net.Resolver sending its queries to r
+func (r *countingResolver) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			r.queries.Add(1)
+			n := r.inflight.Add(1)
+			defer r.inflight.Add(-1)
+			for {
+				most := r.most.Load()
+				if n <= most || r.most.CompareAndSwap(most, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil, errors.New("no DNS server in tests")
+		},
+	}
+}
+
+// mostConcurrentLookups dials ten hosts at once with the given lookup limit
+// and returns the most DNS queries that were in flight at the same time
+func mostConcurrentLookups(t *testing.T, limit int) int64 {
+	t.Helper()
+	counter := &countingResolver{}
+	d := NewDialer(0)
+	d.dialer.Resolver = counter.resolver()
+	d.SetMaxConcurrentDNS(limit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if conn, err := d.DialContext(context.Background(), "tcp4", fmt.Sprintf("host%d.invalid:80", i)); err == nil {
+				conn.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if counter.queries.Load() < 10 {
+		t.Fatalf("resolver saw %d queries for 10 hosts", counter.queries.Load())
+	}
+	return counter.most.Load()
+}
+
+func TestMaxConcurrentDNSCapsLookups(t *testing.T) {
+	if most := mostConcurrentLookups(t, 2); most > 2 {
+		t.Errorf("%d DNS queries in flight at once, want at most max_concurrent_dns of 2", most)
+	}
+	// Without a limit the lookups overlap
+	if most := mostConcurrentLookups(t, 0); most <= 2 {
+		t.Errorf("only %d DNS queries in flight at once without a limit, the test can't tell", most)
+	}
+}
+
+func TestUsersBindDistinctLocalPorts(t *testing.T) {
+	const firstPort, users = 47310, 4
+	var mu sync.Mutex
+	ports := make(map[string]map[int]bool) // Local ports by user, told apart by their source IP
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, portText, _ := net.SplitHostPort(r.RemoteAddr)
+		port, _ := strconv.Atoi(portText)
+		mu.Lock()
+		defer mu.Unlock()
+		user := r.Header.Get("X-Forwarded-For")
+		if ports[user] == nil {
+			ports[user] = make(map[int]bool)
+		}
+		ports[user][port] = true
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = users
+	cfg.LocalPortStart, cfg.LocalPortEnd = firstPort, firstPort+users-1
+	cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.0", "10.255.255.255"
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "requesting from every user", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(ports) == users
+	})
+	g.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	used := make(map[int]bool)
+	for user, userPorts := range ports {
+		for port := range userPorts {
+			if port < firstPort || port >= firstPort+users || used[port] {
+				t.Errorf("user %s connected from port %d, want a port of the range no other user has", user, port)
+			}
+			used[port] = true
+		}
+	}
+}
+
+func TestMaxNewConnectionsPerSecCapsRamp(t *testing.T) {
+	const users, perSecond = 30, 20
+	var mu sync.Mutex
+	var opened []time.Time
+	server := httptest.NewUnstartedServer(okHandler)
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			opened = append(opened, time.Now())
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = users
+	cfg.MaxNewConnectionsPerSec = perSecond
+	cfg.RequestsPerSecond = 0
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "connected from every user", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(opened) >= users
+	})
+	g.Stop()
+
+	// All users start at once, but their connections open at the capped rate
+	mu.Lock()
+	defer mu.Unlock()
+	elapsed := opened[users-1].Sub(opened[0])
+	if rate := float64(users-1) / elapsed.Seconds(); rate > perSecond*1.1 {
+		t.Errorf("%d connections opened in %s (%.0f/s), want at most %d/s", users, elapsed, rate, perSecond)
+	}
+}
+
+// flakyResolver is a resolver whose DNS server answers SERVFAIL until it
+// recovers, then resolves every name to 127.0.0.1
+type flakyResolver struct {
+	recovered atomic.Bool
+	queries   atomic.Int64
+}
+
+// resolver returns a net.Resolver sending its queries to r over a pipe
+func (r *flakyResolver) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go r.serve(server)
+			return client, nil
+		},
+	}
+}
+
+// serve answers the length-prefixed DNS queries sent over conn
+func (r *flakyResolver) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		r.queries.Add(1)
+
+		// The question runs from the header to past the name's root label,
+		// its type and its class
+		end := 12
+		for query[end] != 0 {
+			end += int(query[end]) + 1
+		}
+		end += 5
+		question, qtype := query[12:end], binary.BigEndian.Uint16(query[end-4:])
+
+		answer := binary.BigEndian.AppendUint16(nil, binary.BigEndian.Uint16(query))
+		switch {
+		case !r.recovered.Load():
+			answer = append(answer, 0x81, 0x82, 0, 1, 0, 0, 0, 0, 0, 0) // SERVFAIL
+			answer = append(answer, question...)
+		case qtype == 1: // A
+			answer = append(answer, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0)
+			answer = append(answer, question...)
+			answer = append(answer, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+		default:
+			answer = append(answer, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+			answer = append(answer, question...)
+		}
+		if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(answer)))); err != nil {
+			return
+		}
+		if _, err := conn.Write(answer); err != nil {
+			return
+		}
+	}
+}
+
+func TestTemporaryDNSFailuresAreRetried(t *testing.T) {
+	server := httptest.NewServer(okHandler)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	addr := net.JoinHostPort("flaky.test", port)
+
+	// Without retries the temporary failure is the dial's error
+	flaky := &flakyResolver{}
+	d := NewDialer(0)
+	d.dialer.Resolver = flaky.resolver()
+	if _, err := d.DialContext(context.Background(), "tcp4", addr); !isTemporaryDNSError(err) {
+		t.Fatalf("dialing while the resolver fails: error %v, want a temporary DNS error", err)
+	}
+
+	// With retries the dial outlasts the failure
+	flaky = &flakyResolver{}
+	d = NewDialer(0)
+	d.dialer.Resolver = flaky.resolver()
+	d.SetDNSRetries(3, 100*time.Millisecond)
+	time.AfterFunc(50*time.Millisecond, func() { flaky.recovered.Store(true) })
+	conn, err := d.DialContext(context.Background(), "tcp4", addr)
+	if err != nil {
+		t.Fatalf("dialing with DNS retries: %v, want the retry after the resolver recovers to connect", err)
+	}
+	conn.Close()
+	if n := flaky.queries.Load(); n < 2 {
+		t.Errorf("resolver saw %d queries, want the failed lookup retried", n)
+	}
+}
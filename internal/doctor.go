@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"fake-traffic-go/config"
+	"fake-traffic-go/urls"
+)
+
+// CheckStatus is the outcome of a single environment check
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// CheckResult reports the outcome of a single environment check
+type CheckResult struct {
+	Name   string
+	Status CheckStatus
+	Detail string
+}
+
+// Number of URL hosts whose DNS resolution is checked
+const doctorDNSSamples = 5
+
+// RunDoctor checks the environment for problems that would prevent the
+// configuration from running as intended, such as missing privileges for
+// source IP binding, a low open file limit or failing DNS resolution
+func RunDoctor(cfg *config.Config) []CheckResult {
+	results := []CheckResult{checkConfig(cfg), checkSourceIPBinding(cfg.IPRangeStart), checkFileLimit(cfg)}
+	return append(results, checkDNS(cfg.URLFilePath, net.DefaultResolver)...)
+}
+
+// checkConfig reports whether the configuration is valid
+func checkConfig(cfg *config.Config) CheckResult {
+	if err := cfg.Validate(); err != nil {
+		return CheckResult{"config", CheckFail, err.Error()}
+	}
+	return CheckResult{"config", CheckPass, "configuration is valid"}
+}
+
+// checkSourceIPBinding reports whether sockets can be bound to the start of the
+// configured IP range. If not, spoofing is limited to request headers.
+func checkSourceIPBinding(ip string) CheckResult {
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		return CheckResult{"source-ip-binding", CheckWarn,
+			fmt.Sprintf("cannot bind %s (%v); the spoofed IP is only sent in headers", ip, err)}
+	}
+	conn.Close()
+	return CheckResult{"source-ip-binding", CheckPass, fmt.Sprintf("can bind %s", ip)}
+}
+
+// checkFileLimitValue compares the open file limit with the number of
+// connections the configuration may open
+func checkFileLimitValue(limit uint64, cfg *config.Config) CheckResult {
+	// Each user may hold a few connections, plus files for logs and results
+	needed := uint64(cfg.GetConcurrentUsers()*max(1, cfg.PipelineDepth)*2 + 64)
+	if cfg.MaxOpenConnections > 0 {
+		needed = uint64(cfg.MaxOpenConnections + 64)
+	}
+
+	if limit < needed {
+		return CheckResult{"open-file-limit", CheckWarn,
+			fmt.Sprintf("limit %d is below the %d likely needed; raise it with ulimit -n", limit, needed)}
+	}
+	return CheckResult{"open-file-limit", CheckPass, fmt.Sprintf("limit %d", limit)}
+}
+
+// Resolver looks up host names, as net.Resolver does
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// checkDNS resolves the hosts of a sample of the URLs in the URL file
+func checkDNS(urlFilePath string, resolver Resolver) []CheckResult {
+	manager := urls.NewURLManager()
+	if err := manager.LoadFromFile(urlFilePath); err != nil {
+		return []CheckResult{{"dns", CheckFail, fmt.Sprintf("cannot load URLs: %v", err)}}
+	}
+
+	// Draw a sample of distinct hosts
+	hosts := make(map[string]bool)
+	for i := 0; i < doctorDNSSamples*4 && len(hosts) < min(doctorDNSSamples, manager.Count()); i++ {
+		if parsed, err := url.Parse(manager.GetRandomURL()); err == nil && parsed.Hostname() != "" {
+			hosts[parsed.Hostname()] = true
+		}
+	}
+
+	var results []CheckResult
+	for host := range hosts {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		addrs, err := resolver.LookupHost(ctx, host)
+		cancel()
+
+		if err != nil {
+			results = append(results, CheckResult{"dns " + host, CheckFail, err.Error()})
+		} else {
+			results = append(results, CheckResult{"dns " + host, CheckPass, fmt.Sprintf("resolves to %s", addrs[0])})
+		}
+	}
+	return results
+}
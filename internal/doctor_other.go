@@ -0,0 +1,10 @@
+//go:build !unix
+
+package internal
+
+import "fake-traffic-go/config"
+
+// checkFileLimit reports that open file limits can't be checked on this platform
+func checkFileLimit(cfg *config.Config) CheckResult {
+	return CheckResult{"open-file-limit", CheckPass, "not applicable on this platform"}
+}
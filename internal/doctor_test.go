@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"fake-traffic-go/config"
+)
+
+func TestCheckFileLimitValue(t *testing.T) {
+	tests := []struct {
+		limit          uint64
+		users          int
+		pipelineDepth  int
+		maxConnections int
+		want           CheckStatus
+	}{
+		{1024, 10, 1, 0, CheckPass},     // Needs 84
+		{84, 10, 1, 0, CheckPass},       // Exactly enough
+		{83, 10, 1, 0, CheckWarn},       // One short
+		{1024, 100, 8, 0, CheckWarn},    // Pipelining multiplies the connections, needs 1664
+		{1024, 1000, 1, 500, CheckPass}, // Connections capped, needs 564
+		{1024, 10, 1, 2000, CheckWarn},  // Cap above the limit, needs 2064
+	}
+	for _, test := range tests {
+		cfg := config.NewDefaultConfig()
+		cfg.ConcurrentUsers = test.users
+		cfg.PipelineDepth = test.pipelineDepth
+		cfg.MaxOpenConnections = test.maxConnections
+		if got := checkFileLimitValue(test.limit, cfg); got.Status != test.want {
+			t.Errorf("limit %d, %d users, depth %d, max %d connections: %s (%s), want %s",
+				test.limit, test.users, test.pipelineDepth, test.maxConnections, got.Status, got.Detail, test.want)
+		}
+	}
+}
+
+// stubResolver resolves the hosts it knows to a fixed address and fails on the others
+type stubResolver map[string]string
+
+func (r stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addr, ok := r[host]; ok {
+		return []string{addr}, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestCheckDNS(t *testing.T) {
+	cfg := newTestConfig(t, "https://good.example/a", "https://good.example/b", "https://bad.example/")
+	results := checkDNS(cfg.URLFilePath, stubResolver{"good.example": "192.0.2.1"})
+
+	statuses := make(map[string]CheckStatus)
+	for _, result := range results {
+		statuses[result.Name] = result.Status
+	}
+	if len(statuses) != 2 || statuses["dns good.example"] != CheckPass || statuses["dns bad.example"] != CheckFail {
+		t.Errorf("checkDNS() = %+v, want good.example passing and bad.example failing", results)
+	}
+
+	results = checkDNS(filepath.Join(t.TempDir(), "missing.txt"), stubResolver{})
+	if len(results) != 1 || results[0].Name != "dns" || results[0].Status != CheckFail {
+		t.Errorf("checkDNS() without a URL file = %+v, want a single failure", results)
+	}
+}
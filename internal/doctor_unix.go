@@ -0,0 +1,19 @@
+//go:build unix
+
+package internal
+
+import (
+	"fmt"
+	"syscall"
+
+	"fake-traffic-go/config"
+)
+
+// checkFileLimit reports whether the open file limit suits the configuration
+func checkFileLimit(cfg *config.Config) CheckResult {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return CheckResult{"open-file-limit", CheckWarn, fmt.Sprintf("cannot read limit: %v", err)}
+	}
+	return checkFileLimitValue(uint64(limit.Cur), cfg)
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// Actions taken when host budgets leave no URL to select
+const (
+	// PoolExhaustedPause keeps users waiting until URLs become selectable again
+	PoolExhaustedPause = "pause"
+	// PoolExhaustedReload reloads the URL file and resets the host budgets
+	PoolExhaustedReload = "reload"
+	// PoolExhaustedStop stops the generator
+	PoolExhaustedStop = "stop"
+)
+
+// Time a user waits before selecting again after finding the URL pool exhausted,
+// which is also the minimum time between two reports of the exhaustion
+const poolExhaustedInterval = 5 * time.Second
+
+// handlePoolExhausted reacts to a user finding no selectable URL by taking
+// the configured action. Repeated calls within the interval are ignored.
+func (g *TrafficGenerator) handlePoolExhausted() {
+	g.exhaustMutex.Lock()
+	defer g.exhaustMutex.Unlock()
+
+	now := g.clock.Now()
+	if !g.lastExhausted.IsZero() && now.Sub(g.lastExhausted) < poolExhaustedInterval {
+		return
+	}
+	g.lastExhausted = now
+
+	// Not an exhaustion, the URLs come back when a campaign is enabled
+	if g.urlManager.CampaignsDisabled() {
+		fmt.Println("All URLs belong to disabled campaigns, pausing until one is enabled")
+		return
+	}
+
+	switch g.config.PoolExhaustedAction {
+	case PoolExhaustedReload:
+		fmt.Printf("URL pool exhausted by host budgets, reloading %s\n", g.config.URLFilePath)
+		if err := g.urlManager.LoadFromFile(g.config.URLFilePath); err != nil {
+			fmt.Printf("Error reloading URL file: %v\n", err)
+			return
+		}
+		g.urlManager.ResetHostBudgets()
+	case PoolExhaustedStop:
+		g.stopSelf(StopPoolExhausted, "URL pool exhausted by host budgets, stopping traffic generator")
+	default:
+		fmt.Println("URL pool exhausted by host budgets, pausing until URLs become available")
+	}
+}
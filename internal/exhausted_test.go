@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolExhaustionTakesConfiguredAction(t *testing.T) {
+	const budget = 3
+	for _, action := range []string{PoolExhaustedPause, PoolExhaustedReload, PoolExhaustedStop} {
+		t.Run(action, func(t *testing.T) {
+			server := newCountingServer(t, okHandler)
+			cfg := newTestConfig(t, server.URL+"/")
+			cfg.ConcurrentUsers = 1
+			cfg.PerHostRequestBudget = budget
+			cfg.PoolExhaustedAction = action
+			cfg.PerUserRPS = 50
+			cfg.MinThinkTime = 0
+			clock := newFakeClock(time.Date(2026, time.October, 12, 9, 0, 0, 0, time.Local))
+			g := newTestGenerator(t, cfg)
+			g.SetClock(clock)
+			if err := g.Start(); err != nil {
+				t.Fatal(err)
+			}
+			waitUntil(t, "managing users", clock.hasTicker)
+
+			// advance moves time on by 100ms steps, past think times and exhaustion checks
+			requests := func() int64 { return g.GetStatsSnapshot().TotalRequests }
+			advance := func(steps int) {
+				for i := 0; i < steps; i++ {
+					clock.Advance(100 * time.Millisecond)
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			switch action {
+			case PoolExhaustedPause:
+				waitUntil(t, "using up the budget", func() bool { advance(1); return requests() == budget })
+				advance(200) // Several exhaustion intervals
+				if n := requests(); n != budget {
+					t.Errorf("%d requests made while paused, want %d", n, budget)
+				}
+				select {
+				case <-g.SelfStopped():
+					t.Error("generator stopped, want it paused")
+				default:
+				}
+			case PoolExhaustedReload:
+				waitUntil(t, "requesting past the budget after a reload", func() bool { advance(1); return requests() > budget })
+			case PoolExhaustedStop:
+				waitUntil(t, "stopped", func() bool {
+					advance(1)
+					select {
+					case <-g.SelfStopped():
+						return true
+					default:
+						return false
+					}
+				})
+				if reason := g.StopReason(); reason != StopPoolExhausted {
+					t.Errorf("stop reason = %q, want %q", reason, StopPoolExhausted)
+				}
+				if n := requests(); n != budget {
+					t.Errorf("%d requests made, want the budget of %d", n, budget)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ErrInjectedFault is the cause of requests failed on purpose by fault injection
+var ErrInjectedFault = errors.New("injected fault")
+
+// Connection errors an injected fault presents as, one drawn at random per fault
+var injectedFaults = []syscall.Errno{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ETIMEDOUT}
+
+// faultTransport fails a share of requests with a connection error before they
+// reach the network, to exercise error accounting and the reactions to errors
+type faultTransport struct {
+	next http.RoundTripper
+	rate float64
+	rand *rand.Rand
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.rand.Float64() >= t.rate {
+		return t.next.RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	errno := injectedFaults[t.rand.Intn(len(injectedFaults))]
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("%w: %w", ErrInjectedFault, errno)}
+}
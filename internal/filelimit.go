@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// Minimum time between two concurrency reductions caused by file limit errors,
+// giving removed users time to release their sockets
+const fileLimitBackoffInterval = 5 * time.Second
+
+// isFileLimitError reports whether err was caused by the process or the system
+// running out of file descriptors
+func isFileLimitError(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// handleFileLimitError reacts to a request failing for lack of file descriptors
+// by explaining the problem and, if enabled, halving the number of users
+func (g *TrafficGenerator) handleFileLimitError() {
+	g.backoffMutex.Lock()
+	defer g.backoffMutex.Unlock()
+
+	now := g.clock.Now()
+	if !g.lastBackoff.IsZero() && now.Sub(g.lastBackoff) < fileLimitBackoffInterval {
+		return
+	}
+	g.lastBackoff = now
+
+	fmt.Println("Too many open files: raise the file descriptor limit (e.g. ulimit -n 65535) or reduce concurrent users")
+	if !g.config.FileLimitBackoff {
+		return
+	}
+
+	current := g.config.GetConcurrentUsers()
+	next := max(1, current/2)
+	if next < current {
+		fmt.Printf("Reducing concurrent users from %d to %d\n", current, next)
+		g.config.SetConcurrentUsers(next)
+	}
+}
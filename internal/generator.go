@@ -1,35 +1,103 @@
 package internal
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"fake-traffic-go/config"
+	"fake-traffic-go/grpc"
 	"fake-traffic-go/ipspoof"
 	"fake-traffic-go/urls"
 )
 
 // TrafficGenerator coordinates traffic generation
 type TrafficGenerator struct {
-	config        *config.Config
-	urlManager    *urls.URLManager
-	ipSpoofer     *ipspoof.IPSpoofer
-	users         map[int]*BrowserUser
-	usersMutex    sync.Mutex
-	wg            sync.WaitGroup
-	running       bool
-	stopChan      chan struct{}
-	requestCount  int64
-	requestsMutex sync.Mutex
-	requestsStart time.Time
+	config          *config.Config
+	urlManager      *urls.URLManager
+	selector        urls.URLSelector
+	latencySelector *urls.LatencySelector // nil unless latency bias is set
+	ipSpoofer       *ipspoof.IPSpoofer
+	limiter         *RateLimiter
+	log             *slog.Logger // Logs request events, sampled at log_sample_rate
+	dialer          *Dialer
+	transport       *http.Transport // Shared by all users, nil unless configured
+	inflight        *inflightLimiter
+	upload          *uploadLimiter // nil unless uploads are limited
+	proxies         *ProxyPool
+	localPorts      *portPool // nil unless local ports are configured
+	users           map[int]*BrowserUser
+	nextUserID      int
+	churnDebt       float64 // Users due to be recycled, carried over between ticks
+	usersMutex      sync.Mutex
+	activeUsers     atomic.Pointer[[]*BrowserUser] // Published copy of users, sorted by ID
+	wg              sync.WaitGroup
+	running         bool
+	runningMutex    sync.Mutex
+	stopChan        chan struct{}
+	managerDone     chan struct{}
+	ctx             context.Context
+	cancel          context.CancelFunc
+	requestCount    atomic.Int64 // Updated without locking on the request path
+	requestsMutex   sync.Mutex   // Guards requestsStart and counter window resets
+	requestsStart   time.Time
+	stats           *requestStats
+	latencies       *latencyWindow // nil unless adaptive concurrency is enabled
+	backoffMutex    sync.Mutex
+	lastBackoff     time.Time
+	seedRand        *rand.Rand
+	seedMutex       sync.Mutex
+	resultWriter    *ResultWriter
+	recent          *RecentResults // nil unless recent results are retained
+	sinks           []ResultSink
+	sinksMutex      sync.RWMutex
+	channel         *resultChannel // nil until Results is called
+	decorators      []RequestDecorator
+	signer          *Signer        // nil unless request signing is configured
+	rules           *ResponseRules // nil unless responses are validated
+	exhaustMutex    sync.Mutex
+	lastExhausted   time.Time
+	lastSuccess     atomic.Int64 // Time of the last successful request, in Unix nanoseconds
+	startedAt       atomic.Int64 // Time of the last Start, in Unix nanoseconds
+	idleReported    bool
+	selfStopped     chan struct{} // Closed when the generator stops itself
+	selfStopOnce    sync.Once
+	stopReason      StopReason        // Set once before selfStopped is closed
+	baseline        *baselineRecorder // nil unless recording or comparing a baseline
+	reference       Baseline          // Baseline to compare with, nil if none
+	grpcMethods     []string          // Methods discovered from the descriptor set, nil if none
+	clock           Clock
+	inSchedule      *bool // Last schedule state applied, nil until first checked
 }
 
 // NewTrafficGenerator creates a new traffic generator
 func NewTrafficGenerator(cfg *config.Config) (*TrafficGenerator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Create URL manager
 	urlManager := urls.NewURLManager()
-	err := urlManager.LoadFromFile(cfg.URLFilePath)
+	err := urlManager.SetShard(cfg.ShardIndex, cfg.ShardCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure URL shard: %w", err)
+	}
+
+	urlManager.SetHostBudget(cfg.PerHostRequestBudget)
+	urlManager.SetMaxLineLength(cfg.MaxURLLength)
+	if err := urlManager.SetAllowlist(cfg.TargetAllowlist); err != nil {
+		return nil, fmt.Errorf("failed to configure target allowlist: %w", err)
+	}
+
+	err = urlManager.LoadFromFile(cfg.URLFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load URLs: %w", err)
 	}
@@ -39,43 +107,192 @@ func NewTrafficGenerator(cfg *config.Config) (*TrafficGenerator, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create IP spoofer: %w", err)
 	}
+	if cfg.IPv6RangeStart != "" {
+		err = ipSpoofer.SetIPv6Range(cfg.IPv6RangeStart, cfg.IPv6RangeEnd, cfg.IPv6Ratio)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure IPv6 range: %w", err)
+		}
+	}
+
+	// Load the proxy pool if configured
+	var proxies *ProxyPool
+	if cfg.ProxyListPath != "" {
+		proxies, err = LoadProxyPool(cfg.ProxyListPath, cfg.RandomProxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load proxies: %w", err)
+		}
+	}
+
+	// Reserve the local port range if configured
+	var localPorts *portPool
+	if cfg.LocalPortStart > 0 {
+		localPorts = newPortPool(cfg.LocalPortStart, cfg.LocalPortEnd)
+	}
+
+	// Bias URL selection by observed latency if configured
+	var latencySelector *urls.LatencySelector
+	if cfg.LatencyBias != 0 {
+		latencySelector = urls.NewLatencySelector(urlManager, cfg.LatencyBias)
+	}
+
+	// Collect latencies for adaptive concurrency if a target is set
+	var latencies *latencyWindow
+	if cfg.TargetP99Ms > 0 {
+		latencies = &latencyWindow{}
+	}
+
+	dialer := NewDialer(cfg.MaxOpenConnections)
+	dialer.SetConnectRate(cfg.MaxNewConnectionsPerSec)
+	dialer.SetMaxConcurrentDNS(cfg.MaxConcurrentDNS)
+	dialer.SetDNSRetries(cfg.DNSRetries, time.Duration(cfg.DNSRetryDelay*float64(time.Second)))
+	if len(cfg.HostOverrides) > 0 || cfg.PinResolvedHosts {
+		dialer.SetHostOverrides(pinnedHosts(urlManager.Hosts(), cfg.HostOverrides, cfg.PinResolvedHosts, net.DefaultResolver))
+	}
+
+	if cfg.FaultInjectionRate > 0 {
+		fmt.Printf("Fault injection enabled: %.1f%% of requests will fail on purpose\n", cfg.FaultInjectionRate*100)
+	}
+
+	// Load the baseline to compare the run with
+	var reference Baseline
+	if cfg.BaselineCompareFile != "" {
+		reference, err = LoadBaseline(cfg.BaselineCompareFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load baseline: %w", err)
+		}
+	}
+
+	// Discover the methods to call in gRPC mode
+	var grpcMethods []string
+	if cfg.GRPCDescriptorSet != "" {
+		grpcMethods, err = grpc.LoadMethods(cfg.GRPCDescriptorSet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC descriptor set: %w", err)
+		}
+	}
+
+	// Retain the last results for the control API
+	var recent *RecentResults
+	var sinks []ResultSink
+	if cfg.RecentResultsSize > 0 {
+		recent = NewRecentResults(cfg.RecentResultsSize)
+		sinks = append(sinks, recent)
+	}
+
+	// A fixed random seed also makes user churn reproducible
+	seed := cfg.RandomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	generator := &TrafficGenerator{
+		config:          cfg,
+		urlManager:      urlManager,
+		latencySelector: latencySelector,
+		ipSpoofer:       ipSpoofer,
+		proxies:         proxies,
+		localPorts:      localPorts,
+		log:             newRequestLogger(os.Stdout, cfg.LogSampleRate),
+		dialer:          dialer,
+		inflight:        newInflightLimiter(cfg.MaxInflightRequests),
+		upload:          newUploadLimiter(cfg.UploadBandwidthLimit),
+		users:           make(map[int]*BrowserUser),
+		stopChan:        make(chan struct{}),
+		requestsStart:   time.Now(),
+		stats:           newRequestStats(),
+		latencies:       latencies,
+		seedRand:        rand.New(rand.NewSource(seed)),
+		clock:           realClock{},
+		reference:       reference,
+		grpcMethods:     grpcMethods,
+		recent:          recent,
+		sinks:           sinks,
+		selfStopped:     make(chan struct{}),
+	}
+	generator.limiter = NewRateLimiter(generator.requestRate)
+	if cfg.SharedTransport {
+		generator.transport = newSharedTransport(dialer, time.Duration(cfg.IdleConnTimeout*float64(time.Second)))
+	}
+	if signing := cfg.RequestSigning; signing != nil {
+		generator.signer = NewSigner(signing.Secret, signing.Header, signing.TimestampHeader)
+	}
+	if validation := cfg.ResponseValidation; validation != nil {
+		rules, err := NewResponseRules(*validation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure response validation: %w", err)
+		}
+		generator.rules = rules
+	}
+	if latencySelector != nil {
+		generator.selector = latencySelector
+	}
 
-	return &TrafficGenerator{
-		config:        cfg,
-		urlManager:    urlManager,
-		ipSpoofer:     ipSpoofer,
-		users:         make(map[int]*BrowserUser),
-		stopChan:      make(chan struct{}),
-		requestCount:  0,
-		requestsStart: time.Now(),
-	}, nil
+	return generator, nil
 }
 
 // Start begins traffic generation
 func (g *TrafficGenerator) Start() error {
+	// Open the connections before the clock of the run starts, without keeping
+	// Stop and other callers waiting on the lock meanwhile
+	if g.transport != nil && g.config.WarmupConnectionsPerHost > 0 {
+		g.warmUp()
+	}
+
+	g.runningMutex.Lock()
+	defer g.runningMutex.Unlock()
+
 	if g.running {
 		return fmt.Errorf("traffic generator is already running")
 	}
 
+	if g.config.ResultsFile != "" {
+		writer, err := NewResultWriter(g.config.ResultsFile)
+		if err != nil {
+			return err
+		}
+		g.resultWriter = writer
+	}
+
+	if g.config.BaselineRecordFile != "" || g.reference != nil {
+		g.baseline = newBaselineRecorder()
+	}
+
+	g.markProgress(g.clock.Now())
+	g.startedAt.Store(g.clock.Now().UnixNano())
 	g.running = true
+	g.stopChan = make(chan struct{})
+	g.managerDone = make(chan struct{})
+	g.ctx, g.cancel = context.WithCancel(context.Background())
 	fmt.Println("Starting traffic generator...")
 
 	// Start the user manager goroutine
-	go g.manageUsers()
+	go func() {
+		defer close(g.managerDone)
+		g.manageUsers()
+	}()
 
 	return nil
 }
 
-// Stop halts traffic generation
+// Stop halts traffic generation. It is safe to call concurrently.
 func (g *TrafficGenerator) Stop() {
+	g.runningMutex.Lock()
+	defer g.runningMutex.Unlock()
+
 	if !g.running {
 		return
 	}
 
 	fmt.Println("Stopping traffic generator...")
+
+	// Stop accepting new users and wait until the manager has exited,
+	// so no user can be started after this point
 	close(g.stopChan)
+	<-g.managerDone
 
-	// Stop all users
+	// Cancel the shared context to release users blocked on the rate limiter
+	// or waiting for a response, then stop each user
+	g.cancel()
 	g.usersMutex.Lock()
 	for _, user := range g.users {
 		user.Stop()
@@ -84,6 +301,29 @@ func (g *TrafficGenerator) Stop() {
 
 	// Wait for all users to finish
 	g.wg.Wait()
+	if g.transport != nil {
+		// Users leave the connections of a shared transport open for each other
+		g.transport.CloseIdleConnections()
+	}
+
+	// Drop the stopped users so a later Start begins from a clean slate
+	g.usersMutex.Lock()
+	g.users = make(map[int]*BrowserUser)
+	g.publishUsers()
+	g.usersMutex.Unlock()
+
+	g.closeResults()
+
+	if g.baseline != nil {
+		g.finishBaseline()
+	}
+
+	if g.resultWriter != nil {
+		if err := g.resultWriter.Close(); err != nil {
+			fmt.Printf("Error closing results file: %v\n", err)
+		}
+		g.resultWriter = nil
+	}
 
 	g.running = false
 	fmt.Println("Traffic generator stopped")
@@ -91,20 +331,37 @@ func (g *TrafficGenerator) Stop() {
 
 // manageUsers continuously adjusts the number of active users based on configuration
 func (g *TrafficGenerator) manageUsers() {
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := g.clock.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
+	ticks := 0
 	for {
 		select {
 		case <-g.stopChan:
 			return
-		case <-ticker.C:
+		case <-ticker.C():
+			g.applySchedule()
+
+			ticks++
+			if g.latencies != nil && ticks%adaptiveInterval == 0 {
+				g.adaptConcurrency()
+			}
+
 			if !g.config.IsEnabled() {
 				// Traffic generation disabled - stop all users
 				g.adjustActiveUsers(0)
+				g.markProgress(g.clock.Now())
 				continue
 			}
 
+			if g.config.IdleTimeout > 0 {
+				g.checkIdle()
+			}
+
+			if g.config.UserChurnRate > 0 {
+				g.churnUsers(time.Second)
+			}
+
 			// Get current target for concurrent users
 			targetUsers := g.config.GetConcurrentUsers()
 
@@ -114,40 +371,204 @@ func (g *TrafficGenerator) manageUsers() {
 	}
 }
 
-// adjustActiveUsers adds or removes users to match the target count
+// stopSelf stops the generator from one of its own goroutines, explaining why
+func (g *TrafficGenerator) stopSelf(reason StopReason, message string) {
+	fmt.Println(message)
+	g.selfStopOnce.Do(func() {
+		g.stopReason = reason
+		close(g.selfStopped)
+	})
+
+	// Stop waits for the manager and the users, one of which is the caller
+	go g.Stop()
+}
+
+// AddRequestDecorator appends a decorator applied to every request of every
+// user, after the decorators added before it. It must be called before Start.
+func (g *TrafficGenerator) AddRequestDecorator(decorator RequestDecorator) {
+	g.decorators = append(g.decorators, decorator)
+}
+
+// SelfStopped returns a channel that is closed when the generator stops
+// itself, such as on an exhausted URL pool or an idle timeout
+func (g *TrafficGenerator) SelfStopped() <-chan struct{} {
+	return g.selfStopped
+}
+
+// SetClock replaces the source of time used by the generator and its users.
+// It must be called before Start.
+func (g *TrafficGenerator) SetClock(clock Clock) {
+	g.requestsMutex.Lock()
+	defer g.requestsMutex.Unlock()
+	g.clock = clock
+	g.requestsStart = clock.Now()
+}
+
+// AddResultSink registers a sink that receives the result of every request.
+// Sinks are called from the users' goroutines, so they must be safe for
+// concurrent use and should return quickly.
+func (g *TrafficGenerator) AddResultSink(sink ResultSink) {
+	g.sinksMutex.Lock()
+	defer g.sinksMutex.Unlock()
+	g.sinks = append(g.sinks, sink)
+}
+
+// RecentResults returns up to n of the most recent request results, newest
+// first, or nil if recent results are not retained
+func (g *TrafficGenerator) RecentResults(n int) []Result {
+	if g.recent == nil {
+		return nil
+	}
+	return g.recent.Recent(n)
+}
+
+// SetURLSelector sets the URL selection strategy used by users created from now on.
+// By default users pick URLs at random from the loaded list.
+func (g *TrafficGenerator) SetURLSelector(selector urls.URLSelector) {
+	g.usersMutex.Lock()
+	defer g.usersMutex.Unlock()
+	g.selector = selector
+}
+
+// applySchedule enables or disables traffic when the schedule moves in or out
+// of a window. Only transitions are applied, so a manual SetEnabled holds
+// until the next window boundary.
+func (g *TrafficGenerator) applySchedule() {
+	if len(g.config.Schedule) == 0 {
+		return
+	}
+
+	inWindow := g.config.InSchedule(g.clock.Now())
+	if g.inSchedule != nil && *g.inSchedule == inWindow {
+		return
+	}
+	g.inSchedule = &inWindow
+
+	if inWindow {
+		fmt.Println("Entering scheduled window, enabling traffic")
+	} else {
+		fmt.Println("Outside scheduled windows, disabling traffic")
+	}
+	g.config.SetEnabled(inWindow)
+}
+
+// adjustActiveUsers adds or removes users to match the target count.
+// Users whose session has ended are replaced by fresh users with a new identity.
 func (g *TrafficGenerator) adjustActiveUsers(targetCount int) {
 	g.usersMutex.Lock()
 	defer g.usersMutex.Unlock()
+	defer g.publishUsers()
+
+	// Forget users that have finished their session
+	for id, user := range g.users {
+		select {
+		case <-user.Done():
+			delete(g.users, id)
+		default:
+		}
+	}
 
 	currentCount := len(g.users)
 
 	// Add users if needed
 	if currentCount < targetCount {
 		for i := currentCount; i < targetCount; i++ {
-			user := NewBrowserUser(i, g.urlManager, g.ipSpoofer, &g.wg, g)
-			g.users[i] = user
+			id := g.nextUserID
+			g.nextUserID++
+
+			user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+			if g.selector != nil {
+				user.SetURLSelector(g.selector)
+			}
+			g.users[id] = user
 			user.Start()
 		}
 		fmt.Printf("Added %d users. Current user count: %d\n", targetCount-currentCount, targetCount)
 	}
 
-	// Remove users if needed
+	// Remove users if needed, newest first
 	if currentCount > targetCount {
-		for i := currentCount - 1; i >= targetCount; i-- {
-			if user, exists := g.users[i]; exists {
-				user.Stop()
-				delete(g.users, i)
-			}
+		ids := make([]int, 0, currentCount)
+		for id := range g.users {
+			ids = append(ids, id)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+
+		for _, id := range ids[:currentCount-targetCount] {
+			g.users[id].Stop()
+			delete(g.users, id)
 		}
 		fmt.Printf("Removed %d users. Current user count: %d\n", currentCount-targetCount, targetCount)
 	}
 }
 
+// nextSeed returns a seed for the random source of the user with the given ID.
+// With a fixed random seed it is derived from that seed and the ID, so every
+// run reproduces each user's sequence. Otherwise seeds are drawn from the
+// generator's own source so users of different generators never end up with
+// correlated sequences.
+func (g *TrafficGenerator) nextSeed(id int) int64 {
+	if g.config.RandomSeed != 0 {
+		return userSeed(g.config.RandomSeed, id)
+	}
+
+	g.seedMutex.Lock()
+	defer g.seedMutex.Unlock()
+	return g.seedRand.Int63()
+}
+
+// userSeed derives a user's seed from the run's seed with the splitmix64
+// finalizer, which spreads consecutive IDs over unrelated seeds
+func userSeed(seed int64, id int) int64 {
+	z := uint64(seed) + uint64(id+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// recordResult accounts for a completed request
+func (g *TrafficGenerator) recordResult(result Result) {
+	if result.Err == nil && result.Invalid == nil && !result.Abandoned && result.Status < 400 {
+		g.markProgress(g.clock.Now())
+	}
+	if result.Err == nil {
+		g.RecordRequest()
+	} else if isFileLimitError(result.Err) {
+		g.handleFileLimitError()
+	}
+	g.stats.record(result)
+	if g.latencies != nil {
+		g.latencies.add(result.Duration)
+	}
+	if g.latencySelector != nil && result.Err == nil {
+		g.latencySelector.Observe(result.Entry, result.Duration)
+	}
+	g.urlManager.RecordHostRequest(result.URL)
+
+	if g.baseline != nil {
+		g.baseline.record(result)
+	}
+
+	// Sinks are called without the lock, as a blocking results channel may
+	// wait for its consumer. The slice is never modified in place, only
+	// replaced or appended to, so it can be read after unlocking.
+	g.sinksMutex.RLock()
+	sinks := g.sinks
+	g.sinksMutex.RUnlock()
+	for _, sink := range sinks {
+		sink.Record(result)
+	}
+
+	if g.resultWriter != nil {
+		if err := g.resultWriter.Write(result); err != nil {
+			fmt.Printf("Error writing result: %v\n", err)
+		}
+	}
+}
+
 // RecordRequest increments the request counter
 func (g *TrafficGenerator) RecordRequest() {
-	g.requestsMutex.Lock()
-	defer g.requestsMutex.Unlock()
-	g.requestCount++
+	g.requestCount.Add(1)
 }
 
 // GetActualRequestsPerSecond calculates the actual requests per second
@@ -155,34 +576,69 @@ func (g *TrafficGenerator) GetActualRequestsPerSecond() float64 {
 	g.requestsMutex.Lock()
 	defer g.requestsMutex.Unlock()
 
-	elapsed := time.Since(g.requestsStart).Seconds()
+	elapsed := g.clock.Now().Sub(g.requestsStart).Seconds()
 	if elapsed < 1 {
 		return 0 // Not enough time has passed for accurate measurement
 	}
 
-	rps := float64(g.requestCount) / elapsed
+	count := g.requestCount.Load()
+	rps := float64(count) / elapsed
 
-	// Reset counters every minute to avoid integer overflow and keep measurement recent
+	// Reset counters every minute to avoid integer overflow and keep measurement recent.
+	// Requests recorded since the count was read are carried over to the new window.
 	if elapsed > 60 {
-		g.requestCount = 0
-		g.requestsStart = time.Now()
+		g.requestCount.Add(-count)
+		g.requestsStart = g.clock.Now()
 	}
 
 	return rps
 }
 
-// GetStats returns statistics about the traffic generation
+// ResetStats zeroes all accumulated statistics, including the requests per
+// second measurement window. It is safe to call while traffic is flowing.
+func (g *TrafficGenerator) ResetStats() {
+	g.requestsMutex.Lock()
+	g.requestCount.Store(0)
+	g.requestsStart = g.clock.Now()
+	g.requestsMutex.Unlock()
+
+	g.stats.reset()
+}
+
+// LatencyHistogram returns the response times of the successful requests made
+// since start or the last reset, counted in buckets of doubling width
+func (g *TrafficGenerator) LatencyHistogram() []HistogramBucket {
+	return g.stats.latencyHistogram()
+}
+
+// GetStats returns statistics about the traffic generation, keyed as in the
+// JSON printed and served by the control API
 func (g *TrafficGenerator) GetStats() map[string]any {
+	return g.GetStatsSnapshot().Map()
+}
+
+// GetStatsSnapshot returns statistics about the traffic generation
+func (g *TrafficGenerator) GetStatsSnapshot() Stats {
 	g.usersMutex.Lock()
 	activeUsers := len(g.users)
 	g.usersMutex.Unlock()
 
-	return map[string]any{
-		"active_users":            activeUsers,
-		"target_users":            g.config.GetConcurrentUsers(),
-		"target_requests_per_sec": g.config.GetRequestsPerSecond(),
-		"actual_requests_per_sec": float64(int(g.GetActualRequestsPerSecond()*100)) / 100, // Round to 2 decimal places
-		"url_count":               g.urlManager.Count(),
-		"enabled":                 g.config.IsEnabled(),
+	stats := Stats{
+		ActiveUsers:          activeUsers,
+		TargetUsers:          g.config.GetConcurrentUsers(),
+		TargetRequestsPerSec: g.config.GetRequestsPerSecond(),
+		ActualRequestsPerSec: float64(int(g.GetActualRequestsPerSecond()*100)) / 100, // Round to 2 decimal places
+		URLCount:             g.urlManager.Count(),
+		Enabled:              g.config.IsEnabled(),
+		ResultsDropped:       -1,
+	}
+	g.stats.fill(&stats)
+
+	g.sinksMutex.RLock()
+	if g.channel != nil {
+		stats.ResultsDropped = g.channel.dropped.Load()
 	}
+	g.sinksMutex.RUnlock()
+
+	return stats
 }
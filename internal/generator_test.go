@@ -0,0 +1,489 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"fake-traffic-go/config"
+	"fake-traffic-go/ipspoof"
+	"fake-traffic-go/urls"
+)
+
+// newTestConfig returns a default configuration without users whose URL file
+// lists the given URLs
+func newTestConfig(t *testing.T, urls ...string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(urls, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.URLFilePath = path
+	cfg.ConcurrentUsers = 0
+	return cfg
+}
+
+// newTestGenerator creates a generator from the configuration, stopped at the end of the test
+func newTestGenerator(t *testing.T, cfg *config.Config) *TrafficGenerator {
+	t.Helper()
+	g, err := NewTrafficGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(g.Stop)
+	return g
+}
+
+// countingServer is a test server counting the connections opened to it
+type countingServer struct {
+	*httptest.Server
+	connections atomic.Int64
+}
+
+func newCountingServer(t *testing.T, handler http.Handler) *countingServer {
+	t.Helper()
+	s := &countingServer{Server: httptest.NewUnstartedServer(handler)}
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.connections.Add(1)
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// okHandler answers every request with a short body
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+// Baseline on a single CPU of a 2026 cloud VM (go test -bench . -benchmem):
+//
+//	BenchmarkRecordRequest   10 ns/op   0 B/op   0 allocs/op
+
+func BenchmarkRecordRequest(b *testing.B) {
+	g := &TrafficGenerator{config: config.NewDefaultConfig()}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.RecordRequest()
+		}
+	})
+}
+
+func TestRecordRequestCountsConcurrentCalls(t *testing.T) {
+	const goroutines, calls = 16, 10000
+	g := &TrafficGenerator{config: config.NewDefaultConfig()}
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				g.RecordRequest()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := g.requestCount.Load(); got != goroutines*calls {
+		t.Errorf("%d requests counted, want %d", got, goroutines*calls)
+	}
+}
+
+// resultSink is a ResultSink passing the results on to a channel, dropping
+// them once it's full
+type resultSink chan Result
+
+func (s resultSink) Record(result Result) {
+	select {
+	case s <- result:
+	default:
+	}
+}
+
+// nextResult waits for the next result recorded by the sink
+func (s resultSink) nextResult(t *testing.T) Result {
+	t.Helper()
+	select {
+	case result := <-s:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request made within 5s")
+		return Result{}
+	}
+}
+
+func TestResultsOfTemplateURLsRecordTheTemplate(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	template := server.URL + "/items/{id:1-1000}"
+	cfg := newTestConfig(t, template)
+	cfg.LatencyBias = 1
+	cfg.ConcurrentUsers = 1
+	g := newTestGenerator(t, cfg)
+	results := make(resultSink, 16)
+	g.AddResultSink(results)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	result := results.nextResult(t)
+	if result.Entry != template {
+		t.Errorf("result entry = %q, want the template %q", result.Entry, template)
+	}
+	if !strings.HasPrefix(result.URL, server.URL+"/items/") || result.URL == template {
+		t.Errorf("result URL = %q, want the template expanded", result.URL)
+	}
+}
+
+func TestGetStatsMatchesSnapshot(t *testing.T) {
+	g := newTestGenerator(t, newTestConfig(t, "https://a.example/"))
+	g.recordResult(Result{URL: "https://a.example/", Status: 200, Proto: "HTTP/1.1", Duration: 20 * time.Millisecond, Campaign: "spring"})
+	g.recordResult(Result{URL: "https://a.example/", Status: 404, Proto: "HTTP/1.1", Duration: 40 * time.Millisecond})
+	g.recordResult(Result{URL: "https://a.example/", Err: errors.New("refused"), Campaign: "spring"})
+
+	snapshot := g.GetStatsSnapshot()
+	if snapshot.TotalRequests != 3 || snapshot.TotalErrors != 1 || snapshot.StatusCodes[404] != 1 {
+		t.Errorf("snapshot totals = %d requests, %d errors, status codes %v", snapshot.TotalRequests, snapshot.TotalErrors, snapshot.StatusCodes)
+	}
+	if snapshot.RequestsByCampaign["spring"] != (CampaignTotals{Requests: 2, Errors: 1}) {
+		t.Errorf("campaign totals = %+v", snapshot.RequestsByCampaign["spring"])
+	}
+	if snapshot.Latency == nil || snapshot.Latency.P50 != 20 {
+		t.Errorf("latency = %+v, want p50 of 20ms", snapshot.Latency)
+	}
+
+	stats := g.GetStats()
+	if stats["total_requests"] != int64(3) || stats["url_count"] != 1 {
+		t.Errorf("stats = %v", stats)
+	}
+	if codes, _ := stats["status_codes"].(map[string]int64); codes["200"] != 1 || codes["404"] != 1 {
+		t.Errorf("status codes = %v", stats["status_codes"])
+	}
+	if _, ok := stats["results_dropped"]; ok {
+		t.Error("results_dropped reported without a result channel")
+	}
+}
+
+// waitForRequests waits until the generator has recorded at least n requests
+func waitForRequests(t *testing.T, g *TrafficGenerator, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for g.GetStatsSnapshot().TotalRequests < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("fewer than %d requests made within 5s", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGeneratorsRunIndependently(t *testing.T) {
+	var hitsA, hitsB atomic.Int64
+	serverA := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hitsA.Add(1) }))
+	serverB := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hitsB.Add(1) }))
+
+	cfgA := newTestConfig(t, serverA.URL+"/a")
+	cfgA.ConcurrentUsers = 1
+	cfgA.IPRangeStart, cfgA.IPRangeEnd = "10.0.0.1", "10.0.0.1"
+	cfgA.PerUserRPS, cfgA.MinThinkTime = 20, 0
+	cfgB := newTestConfig(t, serverB.URL+"/b")
+	cfgB.ConcurrentUsers = 1
+	cfgB.IPRangeStart, cfgB.IPRangeEnd = "10.9.9.9", "10.9.9.9"
+	cfgB.PerUserRPS, cfgB.MinThinkTime = 20, 0
+	a, b := newTestGenerator(t, cfgA), newTestGenerator(t, cfgB)
+	resultsA, resultsB := make(resultSink, 64), make(resultSink, 64)
+	a.AddResultSink(resultsA)
+	b.AddResultSink(resultsB)
+
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForRequests(t, a, 1)
+	waitForRequests(t, b, 1)
+
+	// Stopping one generator leaves the other running
+	a.Stop()
+	requestsA := a.GetStatsSnapshot().TotalRequests
+	requestsB := b.GetStatsSnapshot().TotalRequests
+	waitForRequests(t, b, requestsB+1)
+	b.Stop()
+
+	if got := a.GetStatsSnapshot().TotalRequests; got != requestsA {
+		t.Errorf("stopped generator went on from %d to %d requests", requestsA, got)
+	}
+	statsA, statsB := a.GetStatsSnapshot(), b.GetStatsSnapshot()
+	if statsA.TotalRequests != hitsA.Load() || statsB.TotalRequests != hitsB.Load() {
+		t.Errorf("generators counted %d and %d requests, servers received %d and %d",
+			statsA.TotalRequests, statsB.TotalRequests, hitsA.Load(), hitsB.Load())
+	}
+	for _, check := range []struct {
+		results resultSink
+		url, ip string
+	}{{resultsA, serverA.URL + "/a", "10.0.0.1"}, {resultsB, serverB.URL + "/b", "10.9.9.9"}} {
+		close(check.results)
+		for result := range check.results {
+			if result.URL != check.url || result.SourceIP != check.ip {
+				t.Errorf("result for %s from %s, want only %s from %s", result.URL, result.SourceIP, check.url, check.ip)
+			}
+		}
+	}
+}
+
+func TestResetStatsRestartsCountsFromZero(t *testing.T) {
+	g := newTestGenerator(t, newTestConfig(t, "https://a.example/"))
+	for i := 0; i < 5; i++ {
+		g.recordResult(Result{URL: "https://a.example/", Status: 200, Proto: "HTTP/1.1", Duration: time.Millisecond, Bytes: 10, Class: "reader"})
+	}
+	g.recordResult(Result{URL: "https://a.example/", Err: errors.New("refused")})
+
+	// Reset through the control API, as between test phases
+	recorder := httptest.NewRecorder()
+	NewControlHandler(g).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/stats/reset", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("POST /stats/reset status = %d, want %d", recorder.Code, http.StatusNoContent)
+	}
+
+	stats := g.GetStatsSnapshot()
+	if stats.TotalRequests != 0 || stats.TotalErrors != 0 || stats.TotalBytes != 0 ||
+		len(stats.StatusCodes) != 0 || len(stats.RequestsByClass) != 0 || len(stats.HTTPVersions) != 0 || stats.Latency != nil {
+		t.Errorf("stats after a reset = %+v, want all zero", stats)
+	}
+	for _, bucket := range g.LatencyHistogram() {
+		if bucket.Count != 0 {
+			t.Errorf("histogram bucket from %s counts %d after a reset", bucket.Min, bucket.Count)
+		}
+	}
+
+	g.recordResult(Result{URL: "https://a.example/", Status: 404, Duration: time.Millisecond})
+	if stats := g.GetStatsSnapshot(); stats.TotalRequests != 1 || stats.StatusCodes[404] != 1 || stats.StatusCodes[200] != 0 {
+		t.Errorf("stats after one more request = %d requests, status codes %v", stats.TotalRequests, stats.StatusCodes)
+	}
+}
+
+func TestResetStatsWhileRecording(t *testing.T) {
+	g := newTestGenerator(t, newTestConfig(t, "https://a.example/"))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				g.recordResult(Result{URL: "https://a.example/", Status: 200, Duration: time.Millisecond})
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		g.ResetStats()
+	}
+	wg.Wait()
+
+	g.ResetStats()
+	if stats := g.GetStatsSnapshot(); stats.TotalRequests != 0 {
+		t.Errorf("%d requests counted after the last reset", stats.TotalRequests)
+	}
+}
+
+func TestPerUserRPSPacesEachUser(t *testing.T) {
+	srv := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, srv.URL+"/")
+	cfg.ConcurrentUsers = 2
+	cfg.PerUserRPS = 20
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Measure once both users are running
+	deadline := time.Now().Add(5 * time.Second)
+	for g.GetStatsSnapshot().ActiveUsers < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("users not started within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	g.ResetStats()
+	start := time.Now()
+	time.Sleep(2 * time.Second)
+	requests := g.GetStatsSnapshot().TotalRequests
+	perUser := float64(requests) / 2 / time.Since(start).Seconds()
+
+	if perUser < 14 || perUser > 26 {
+		t.Errorf("users made %.1f requests per second each, want about per_user_rps of 20", perUser)
+	}
+}
+
+func TestStopLeavesNoGoroutines(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/page")
+	cfg.ConcurrentUsers = 20
+	cfg.RequestsPerSecond = 1
+	before := runtime.NumGoroutine()
+
+	g, err := NewTrafficGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// All but the first users are left waiting on the rate limiter
+	deadline := time.Now().Add(5 * time.Second)
+	for g.GetStatsSnapshot().ActiveUsers < cfg.ConcurrentUsers {
+		if time.Now().After(deadline) {
+			t.Fatal("users not started within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitForRequests(t, g, 1)
+	g.Stop()
+
+	// Connections wind down on the server side shortly after the users close them
+	deadline = time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines after Stop, want at most the %d from before Start:\n%s",
+				runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewTrafficGeneratorErrorsMatchSentinels(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+		want   error
+	}{
+		{"reversed IP range", func(cfg *config.Config) { cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.9", "10.0.0.1" }, ipspoof.ErrInvalidIPRange},
+		{"empty URL file", func(cfg *config.Config) { cfg.URLFilePath = newTestConfig(t, "# no URLs").URLFilePath }, urls.ErrEmptyURLFile},
+		{"missing URL file", func(cfg *config.Config) { cfg.URLFilePath = filepath.Join(t.TempDir(), "missing.txt") }, os.ErrNotExist},
+		{"invalid setting", func(cfg *config.Config) { cfg.ConcurrentUsers = -1 }, config.ErrConfigInvalid},
+		{"mixed IP families", func(cfg *config.Config) { cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.1", "2001:db8::1" }, config.ErrIPFamilyMismatch},
+	}
+	for _, test := range tests {
+		cfg := newTestConfig(t, "https://a.example/")
+		test.modify(cfg)
+		if _, err := NewTrafficGenerator(cfg); !errors.Is(err, test.want) {
+			t.Errorf("%s: NewTrafficGenerator() error = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	cfg := config.NewDefaultConfig()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"concurrent_users": "many"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFromFile(path); !errors.Is(err, config.ErrConfigInvalid) {
+		t.Errorf("LoadFromFile() error = %v, want %v", err, config.ErrConfigInvalid)
+	}
+}
+
+func TestFileLimitErrorsBackOffConcurrency(t *testing.T) {
+	cfg := newTestConfig(t, "http://target.invalid/")
+	cfg.ConcurrentUsers = 8
+	cfg.FileLimitBackoff = true
+	cfg.SharedTransport = true
+	g := newTestGenerator(t, cfg)
+
+	// Every connection fails as if the process ran out of file descriptors
+	g.transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("socket", syscall.EMFILE)}
+	}
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for cfg.GetConcurrentUsers() == 8 {
+		if time.Now().After(deadline) {
+			t.Fatal("concurrency not reduced within 5s of too many open files errors")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Further errors within the backoff interval leave the removed users time to close their sockets
+	time.Sleep(200 * time.Millisecond)
+	if n := cfg.GetConcurrentUsers(); n != 4 {
+		t.Errorf("concurrent users = %d, want 8 halved once to 4", n)
+	}
+}
+
+func TestMaxInflightRequestsCapsConcurrency(t *testing.T) {
+	var inflight, peak atomic.Int64
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for most := peak.Load(); n > most && !peak.CompareAndSwap(most, n); most = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = 10
+	cfg.MaxInflightRequests = 3
+	cfg.BurstSize = 1 << 30 // Back-to-back requests, without thinking
+	cfg.RequestsPerSecond = 0
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForRequests(t, g, 30)
+	g.Stop()
+
+	if n := peak.Load(); n != 3 {
+		t.Errorf("10 users had up to %d requests in flight, want the ceiling of 3 reached and held", n)
+	}
+}
+
+func TestFaultInjectionErrorRate(t *testing.T) {
+	var hits atomic.Int64
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.FaultInjectionRate = 0.2
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	defer user.client.CloseIdleConnections()
+
+	const requests = 2000
+	faults := 0
+	for i := 0; i < requests; i++ {
+		if _, err := user.send(g.ctx, user.client, server.URL+"/", nil, 0); errors.Is(err, ErrInjectedFault) {
+			faults++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Within about five standard deviations, and accounted as errors
+	if rate := float64(faults) / requests; rate < 0.155 || rate > 0.245 {
+		t.Errorf("%.1f%% of requests failed, want the injected 20%%", rate*100)
+	}
+	stats := g.GetStatsSnapshot()
+	if stats.TotalErrors != int64(faults) || stats.TotalRequests != requests {
+		t.Errorf("stats count %d errors in %d requests, want %d in %d", stats.TotalErrors, stats.TotalRequests, faults, requests)
+	}
+	if n := hits.Load(); n != requests-int64(faults) {
+		t.Errorf("server saw %d requests, want only the %d without a fault", n, requests-faults)
+	}
+}
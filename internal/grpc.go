@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"fake-traffic-go/grpc"
+)
+
+// Mode sending gRPC calls instead of browsing, as used in Config.Mode
+const ModeGRPC = "grpc"
+
+// methodURL returns the URL calling a method drawn from the descriptor set on
+// the host named by url, or url itself if no descriptor set is loaded
+func (u *BrowserUser) methodURL(url string) string {
+	if len(u.grpcMethods) == 0 {
+		return url
+	}
+	return strings.TrimSuffix(url, "/") + u.grpcMethods[u.rand.Intn(len(u.grpcMethods))]
+}
+
+// Invoke makes a gRPC unary call to the method named by the URL, of the form
+// https://host/package.Service/Method, sending the serialized protobuf message.
+// A call answered with a status other than OK is reported as an error.
+func (c *HTTPClient) Invoke(ctx context.Context, url string, message []byte) (Result, error) {
+	if err := grpc.CheckURL(url); err != nil {
+		return Result{}, fmt.Errorf("error creating request: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, url, grpc.ContentType, grpc.Frame(message))
+}
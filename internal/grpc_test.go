@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"fake-traffic-go/grpc"
+)
+
+// grpcTestServer is a gRPC server over HTTP/2 answering every unary call with
+// an empty message and counting the calls by method
+type grpcTestServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newGRPCTestServer(t *testing.T) *grpcTestServer {
+	t.Helper()
+	s := &grpcTestServer{calls: make(map[string]int)}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != grpc.ContentType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		s.mu.Lock()
+		s.calls[r.URL.Path]++
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", grpc.ContentType)
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(grpc.Frame(nil))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// callCounts returns a copy of the calls received by method
+func (s *grpcTestServer) callCounts() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int, len(s.calls))
+	for method, n := range s.calls {
+		counts[method] = n
+	}
+	return counts
+}
+
+// writeDescriptorSet writes a descriptor set declaring the unary methods
+// echo.Echo/Ping and echo.Echo/Status and the streaming method echo.Echo/Watch
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("echo.proto"),
+		Package: proto.String("echo"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("Ping"), InputType: proto.String(".echo.Empty"), OutputType: proto.String(".echo.Empty")},
+				{Name: proto.String("Status"), InputType: proto.String(".echo.Empty"), OutputType: proto.String(".echo.Empty")},
+				{Name: proto.String("Watch"), InputType: proto.String(".echo.Empty"), OutputType: proto.String(".echo.Empty"), ServerStreaming: proto.Bool(true)},
+			},
+		}},
+	}}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "echo.pb")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGRPCModeCallsDiscoveredMethods(t *testing.T) {
+	server := newGRPCTestServer(t)
+	cfg := newTestConfig(t, server.URL)
+	cfg.Mode = ModeGRPC
+	cfg.GRPCDescriptorSet = writeDescriptorSet(t)
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	user.client.transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	user.Start()
+	waitForRequests(t, g, 20)
+	g.Stop()
+
+	calls := server.callCounts()
+	if calls["/echo.Echo/Ping"] == 0 || calls["/echo.Echo/Status"] == 0 {
+		t.Errorf("calls by method = %v, want both unary methods called", calls)
+	}
+	if n := calls["/echo.Echo/Watch"]; n != 0 {
+		t.Errorf("streaming method called %d times, want none", n)
+	}
+	stats := g.GetStatsSnapshot()
+	total := 0
+	for _, n := range calls {
+		total += n
+	}
+	if stats.TotalErrors != 0 || int64(total) != stats.TotalRequests {
+		t.Errorf("generator counted %d calls with %d errors, server received %d", stats.TotalRequests, stats.TotalErrors, total)
+	}
+}
+
+func TestGRPCDescriptorSetWithoutUnaryMethods(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pb")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := grpc.LoadMethods(path); !errors.Is(err, grpc.ErrNoUnaryMethods) {
+		t.Errorf("LoadMethods() error = %v, want ErrNoUnaryMethods", err)
+	}
+}
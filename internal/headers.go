@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"math/rand"
+	"net/http"
+)
+
+// Accept header values sent by common browsers for page navigations
+var acceptVariants = []string{
+	"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
+	"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+	"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+}
+
+// setRandomizedHeaders applies the common browser headers for a locale with
+// small plausible variations, so repeated requests are not byte-identical
+func setRandomizedHeaders(header http.Header, locale string, r *rand.Rand) {
+	header.Set("Accept", acceptVariants[r.Intn(len(acceptVariants))])
+
+	// Vary the quality values of the fallback languages
+	header.Set("Accept-Language", acceptLanguage(locale, r))
+
+	// Not every client sends these
+	if r.Float64() < 0.8 {
+		header.Set("Upgrade-Insecure-Requests", "1")
+	}
+	if r.Float64() < 0.7 {
+		header.Set("Cache-Control", "max-age=0")
+	}
+	if r.Float64() < 0.3 {
+		header.Set("DNT", "1")
+	}
+}
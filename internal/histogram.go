@@ -0,0 +1,44 @@
+package internal
+
+import "time"
+
+// Upper bound of the first latency histogram bucket, each following bucket
+// doubling the bound of the previous one
+const histogramBase = time.Millisecond
+
+// Number of latency histogram buckets, the last one collecting everything
+// slower than the others
+const histogramBuckets = 16
+
+// HistogramBucket counts the successful requests answered within a latency range
+type HistogramBucket struct {
+	Min   time.Duration
+	Max   time.Duration // Exclusive, 0 for the unbounded last bucket
+	Count int64
+}
+
+// histogramBucket returns the index of the latency histogram bucket for d
+func histogramBucket(d time.Duration) int {
+	bucket := 0
+	for bound := histogramBase; d >= bound && bucket < histogramBuckets-1; bound *= 2 {
+		bucket++
+	}
+	return bucket
+}
+
+// histogram returns the latency histogram buckets with the given counts
+func histogram(counts [histogramBuckets]int64) []HistogramBucket {
+	buckets := make([]HistogramBucket, histogramBuckets)
+	bound := histogramBase
+	for i, count := range counts {
+		buckets[i].Count = count
+		if i > 0 {
+			buckets[i].Min = buckets[i-1].Max
+		}
+		if i < histogramBuckets-1 {
+			buckets[i].Max = bound
+			bound *= 2
+		}
+	}
+	return buckets
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramBucket(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		want    int
+	}{
+		{0, 0},
+		{time.Millisecond - 1, 0},
+		{time.Millisecond, 1},
+		{2*time.Millisecond - 1, 1},
+		{2 * time.Millisecond, 2},
+		{4 * time.Millisecond, 3},
+		{histogramBase<<(histogramBuckets-2) - 1, histogramBuckets - 2},
+		{histogramBase << (histogramBuckets - 2), histogramBuckets - 1},
+		{time.Hour, histogramBuckets - 1},
+	}
+	for _, test := range tests {
+		if got := histogramBucket(test.latency); got != test.want {
+			t.Errorf("histogramBucket(%s) = %d, want %d", test.latency, got, test.want)
+		}
+	}
+}
+
+func TestHistogramBucketBounds(t *testing.T) {
+	var counts [histogramBuckets]int64
+	counts[1] = 7
+	buckets := histogram(counts)
+
+	if len(buckets) != histogramBuckets {
+		t.Fatalf("histogram() returned %d buckets, want %d", len(buckets), histogramBuckets)
+	}
+	// Every latency falls in the bucket whose bounds contain it
+	for _, latency := range []time.Duration{0, time.Millisecond, 3 * time.Millisecond, time.Second, time.Hour} {
+		bucket := buckets[histogramBucket(latency)]
+		if latency < bucket.Min || (bucket.Max != 0 && latency >= bucket.Max) {
+			t.Errorf("%s in bucket %s-%s", latency, bucket.Min, bucket.Max)
+		}
+	}
+	if first := buckets[0]; first.Min != 0 || first.Max != time.Millisecond {
+		t.Errorf("first bucket %s-%s, want 0s-1ms", first.Min, first.Max)
+	}
+	if last := buckets[histogramBuckets-1]; last.Max != 0 {
+		t.Errorf("last bucket ends at %s, want it unbounded", last.Max)
+	}
+	if buckets[1].Count != 7 {
+		t.Errorf("bucket 1 count = %d, want 7", buckets[1].Count)
+	}
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Time allowed for a QUIC handshake before the target is reached over TCP instead
+const http3HandshakeTimeout = 3 * time.Second
+
+// http3Transport sends requests to https targets over HTTP/3, falling back to
+// the TCP transport, and so HTTP/2 or HTTP/1.1, for hosts whose QUIC handshake
+// fails, such as those not offering HTTP/3 or behind a firewall dropping UDP.
+// Hosts that failed are remembered, by address, and reached over TCP from then on.
+type http3Transport struct {
+	quic     *http3.RoundTripper
+	fallback http.RoundTripper
+	dialer   *Dialer         // Applies host overrides to QUIC connections, may be nil
+	tcpOnly  map[string]bool // Addresses whose QUIC handshake failed
+	mu       sync.Mutex
+}
+
+// newHTTP3Transport creates an HTTP/3 transport falling back to the given one.
+// QUIC connections go to the host overrides of the dialer, if set, but are not
+// counted against its connection limits.
+func newHTTP3Transport(fallback *http.Transport, dialer *Dialer) *http3Transport {
+	t := &http3Transport{
+		fallback: fallback,
+		dialer:   dialer,
+		tcpOnly:  make(map[string]bool),
+	}
+	var tlsConfig *tls.Config
+	if fallback.TLSClientConfig != nil {
+		tlsConfig = fallback.TLSClientConfig.Clone()
+	}
+	t.quic = &http3.RoundTripper{
+		TLSClientConfig: tlsConfig,
+		QUICConfig:      &quic.Config{HandshakeIdleTimeout: http3HandshakeTimeout},
+		Dial:            t.dial,
+	}
+	return t
+}
+
+// dial opens a QUIC connection to the address, or the address its host is
+// pinned to, remembering the address as TCP only if the handshake fails
+func (t *http3Transport) dial(ctx context.Context, addr string, tlsConfig *tls.Config, config *quic.Config) (quic.EarlyConnection, error) {
+	target := addr
+	if t.dialer != nil {
+		target = t.dialer.pinnedAddr(addr)
+	}
+	conn, err := quic.DialAddrEarly(ctx, target, tlsConfig, config)
+	if err != nil && ctx.Err() == nil {
+		t.mu.Lock()
+		t.tcpOnly[strings.ToLower(addr)] = true
+		t.mu.Unlock()
+	}
+	return conn, err
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addr := quicAddr(req.URL)
+	if req.URL.Scheme != "https" || t.isTCPOnly(addr) {
+		return t.fallback.RoundTrip(req)
+	}
+
+	// Only a failed handshake, which sent nothing, falls back to TCP
+	resp, err := t.quic.RoundTrip(req)
+	if err == nil || !t.isTCPOnly(addr) {
+		return resp, err
+	}
+
+	// The body was not sent before the handshake failed, but may have been read
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.fallback.RoundTrip(req)
+}
+
+// isTCPOnly reports whether the QUIC handshake with the address failed before
+func (t *http3Transport) isTCPOnly(addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tcpOnly[addr]
+}
+
+// quicAddr returns the lower-cased host and port QUIC connections for the URL are dialed to
+func quicAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return strings.ToLower(net.JoinHostPort(u.Hostname(), port))
+}
+
+// CloseIdleConnections closes the idle connections of both transports
+func (t *http3Transport) CloseIdleConnections() {
+	t.quic.CloseIdleConnections()
+	if closer, ok := t.fallback.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
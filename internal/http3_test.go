@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3TestServer starts an HTTPS server over TCP and, if quic is set, an
+// HTTP/3 server on the same port over UDP, both answering with the protocol
+// the request arrived over
+func newHTTP3TestServer(t *testing.T, quic bool) *httptest.Server {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	srv := httptest.NewTLSServer(handler)
+	t.Cleanup(srv.Close)
+	if !quic {
+		return srv
+	}
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: srv.TLS.Certificates}),
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+	})
+	return srv
+}
+
+// newHTTP3TestClient returns a client speaking HTTP/3 that trusts the test server
+func newHTTP3TestClient(srv *httptest.Server) *HTTPClient {
+	c := NewHTTPClient(nil)
+	c.transport.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	c.setHTTP3(nil)
+	return c
+}
+
+func TestHTTP3RequestsGoOverQUIC(t *testing.T) {
+	srv := newHTTP3TestServer(t, true)
+	c := newHTTP3TestClient(srv)
+	defer c.http3.quic.Close()
+
+	for i := 0; i < 3; i++ {
+		result, err := c.Get(context.Background(), srv.URL+"/"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if result.Status != http.StatusOK || result.Proto != "HTTP/3.0" {
+			t.Fatalf("request %d: status %d over %s, want 200 over HTTP/3.0", i, result.Status, result.Proto)
+		}
+	}
+}
+
+func TestHTTP3FallsBackToTCPWhenHandshakeFails(t *testing.T) {
+	srv := newHTTP3TestServer(t, false)
+	c := newHTTP3TestClient(srv)
+	c.http3.quic.QUICConfig.HandshakeIdleTimeout = 200 * time.Millisecond
+	defer c.http3.quic.Close()
+
+	for i := 0; i < 2; i++ {
+		result, err := c.Get(context.Background(), srv.URL+"/")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if result.Status != http.StatusOK || result.Proto != "HTTP/1.1" {
+			t.Fatalf("request %d: status %d over %s, want 200 over HTTP/1.1", i, result.Status, result.Proto)
+		}
+	}
+	if !c.http3.isTCPOnly(srv.Listener.Addr().String()) {
+		t.Error("host whose handshake failed is not remembered as TCP only")
+	}
+}
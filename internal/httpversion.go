@@ -0,0 +1,21 @@
+package internal
+
+import "fake-traffic-go/config"
+
+// HTTP versions a user's client can be set to speak, as used in Config.HTTPVersions
+const (
+	HTTPVersion11 = "1.1"
+	HTTPVersion2  = "2"
+)
+
+// assignHTTPVersion draws the HTTP version the user speaks from the configured
+// versions by weight, leaving the client's default negotiation when none are set
+func (u *BrowserUser) assignHTTPVersion(versions []config.HTTPVersion) {
+	weight := func(v config.HTTPVersion) float64 { return v.Weight }
+	version := pickWeighted(versions, weight, u.rand.Float64())
+	if version == nil {
+		return
+	}
+	u.HTTPVersion = version.Version
+	u.client.SetHTTPVersion(u.HTTPVersion)
+}
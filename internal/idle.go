@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// markProgress notes that the target answered successfully at the given time
+func (g *TrafficGenerator) markProgress(at time.Time) {
+	g.lastSuccess.Store(at.UnixNano())
+}
+
+// checkIdle reports when no request has succeeded within the idle timeout
+// and, if configured, stops the generator. Called by the user manager.
+func (g *TrafficGenerator) checkIdle() {
+	timeout := time.Duration(g.config.IdleTimeout * float64(time.Second))
+	idle := g.clock.Now().Sub(time.Unix(0, g.lastSuccess.Load()))
+	if idle < timeout {
+		g.idleReported = false
+		return
+	}
+	if g.idleReported {
+		return
+	}
+	g.idleReported = true
+
+	if g.config.IdleStop {
+		g.stopSelf(StopIdleTimeout, fmt.Sprintf("No successful request for %s, stopping traffic generator", idle.Round(time.Second)))
+		return
+	}
+	fmt.Printf("No successful request for %s, the target may be unreachable\n", idle.Round(time.Second))
+}
@@ -0,0 +1,38 @@
+package internal
+
+import "context"
+
+// inflightLimiter caps the number of requests in flight at once across all
+// clients of a generator. A nil limiter allows any number.
+type inflightLimiter struct {
+	slots chan struct{}
+}
+
+// newInflightLimiter creates a limiter allowing limit requests in flight,
+// or returns nil if limit is 0 or less
+func newInflightLimiter(limit int) *inflightLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &inflightLimiter{slots: make(chan struct{}, limit)}
+}
+
+// acquire waits for a free slot, returning the context's error if it ends first
+func (l *inflightLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *inflightLimiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
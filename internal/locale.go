@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"fake-traffic-go/config"
+)
+
+// DefaultLocale is the locale of users when no locales are configured
+const DefaultLocale = "en-US"
+
+// assignLocale draws the user's locale from the configured locales by weight,
+// keeping the default locale when none are set
+func (u *BrowserUser) assignLocale(locales []config.Locale) {
+	weight := func(l config.Locale) float64 { return l.Weight }
+	locale := pickWeighted(locales, weight, u.rand.Float64())
+	if locale == nil {
+		return
+	}
+	u.Locale = locale.Tag
+	u.client.SetLocale(u.Locale)
+}
+
+// acceptLanguage renders the Accept-Language header of a locale: the tag
+// followed by its base language and English as fallbacks of decreasing quality,
+// e.g. "fr-FR,fr;q=0.9,en;q=0.8". The first fallback has a quality of 0.9, or
+// one drawn between 0.5 and 0.9 when r is set.
+func acceptLanguage(tag string, r *rand.Rand) string {
+	var fallbacks []string
+	base, _, regional := strings.Cut(tag, "-")
+	base = strings.ToLower(base)
+	if regional {
+		fallbacks = append(fallbacks, base)
+	}
+	if base != "en" {
+		fallbacks = append(fallbacks, "en")
+	}
+
+	quality := 9
+	if r != nil {
+		quality = 5 + r.Intn(5)
+	}
+	var b strings.Builder
+	b.WriteString(tag)
+	for _, language := range fallbacks {
+		fmt.Fprintf(&b, ",%s;q=0.%d", language, quality)
+		quality = max(1, quality-1)
+	}
+	return b.String()
+}
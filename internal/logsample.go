@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// samplingHandler is a slog.Handler thinning out high-volume request log
+// records so that at high request rates only one in every rate records below
+// warning level is passed on. Warnings and errors are always passed on.
+type samplingHandler struct {
+	next  slog.Handler
+	rate  int64
+	count *atomic.Int64 // Shared with the handlers derived from this one
+}
+
+// newSamplingHandler creates a handler passing one in rate records on to next
+// (rate <= 1 passes all)
+func newSamplingHandler(next slog.Handler, rate int) *samplingHandler {
+	return &samplingHandler{next: next, rate: int64(rate), count: new(atomic.Int64)}
+}
+
+// Enabled implements slog.Handler
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler, dropping the records left out of the sample
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < slog.LevelWarn && h.rate > 1 && (h.count.Add(1)-1)%h.rate != 0 {
+		return nil
+	}
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs), rate: h.rate, count: h.count}
+}
+
+// WithGroup implements slog.Handler
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name), rate: h.rate, count: h.count}
+}
+
+// messageHandler is a slog.Handler writing the bare message of each record on
+// a line of its own, keeping the request log in its familiar console format.
+// Attributes are ignored.
+type messageHandler struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+// Enabled implements slog.Handler
+func (h messageHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler
+func (h messageHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, record.Message+"\n")
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (h messageHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup implements slog.Handler
+func (h messageHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// newRequestLogger returns the logger of request events, writing one in rate
+// of them to w (rate <= 1 writes all)
+func newRequestLogger(w io.Writer, rate int) *slog.Logger {
+	return slog.New(newSamplingHandler(messageHandler{w: w, mu: new(sync.Mutex)}, rate))
+}
+
+// Logger of request events used unless a generator sets its own, writing all of them
+var defaultRequestLogger = newRequestLogger(os.Stdout, 1)
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRequestLoggerSamplesOneInRate(t *testing.T) {
+	var out bytes.Buffer
+	logger := newRequestLogger(&out, 10)
+	for i := 0; i < 1000; i++ {
+		logger.Info(fmt.Sprintf("User 1 visited /%d", i))
+	}
+	for i := 0; i < 5; i++ {
+		logger.Error(fmt.Sprintf("User 1 error requesting /%d", i))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	visited, failures := 0, 0
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, "visited"):
+			visited++
+		case strings.Contains(line, "error"):
+			failures++
+		}
+	}
+	if visited < 90 || visited > 110 {
+		t.Errorf("%d of 1000 request events logged at rate 10, want about 100", visited)
+	}
+	if failures != 5 {
+		t.Errorf("%d of 5 errors logged, want all of them", failures)
+	}
+	if lines[0] != "User 1 visited /0" {
+		t.Errorf("first line = %q, want the bare message", lines[0])
+	}
+}
+
+func TestRequestLoggerWithoutRateLogsAll(t *testing.T) {
+	for _, rate := range []int{0, 1} {
+		var out bytes.Buffer
+		logger := newRequestLogger(&out, rate)
+		for i := 0; i < 50; i++ {
+			logger.Info("User 1 visited /")
+		}
+		if n := strings.Count(out.String(), "\n"); n != 50 {
+			t.Errorf("rate %d: %d of 50 events logged, want all", rate, n)
+		}
+	}
+}
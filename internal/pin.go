@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Time allowed for resolving all hosts when pinning them at startup
+const pinResolveTimeout = 30 * time.Second
+
+// pinnedHosts returns the addresses connections to each host are pinned to:
+// the configured overrides and, if resolve is set, the first address every
+// other host resolves to now. Hosts that fail to resolve are left unpinned.
+func pinnedHosts(hosts []string, overrides map[string]string, resolve bool, resolver Resolver) map[string]string {
+	pinned := make(map[string]string, len(overrides))
+	for host, ip := range overrides {
+		pinned[strings.ToLower(host)] = ip
+	}
+	if !resolve {
+		return pinned
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pinResolveTimeout)
+	defer cancel()
+
+	for _, host := range hosts {
+		if _, ok := pinned[host]; ok || net.ParseIP(host) != nil {
+			continue
+		}
+		addrs, err := resolver.LookupHost(ctx, host)
+		if err != nil || len(addrs) == 0 {
+			fmt.Printf("Warning: could not resolve %s to pin it: %v\n", host, err)
+			continue
+		}
+		pinned[host] = addrs[0]
+	}
+	return pinned
+}
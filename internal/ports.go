@@ -0,0 +1,39 @@
+package internal
+
+import "sync"
+
+// portPool hands out distinct local ports from a range, so that no two users
+// bind the same source port at the same time
+type portPool struct {
+	free []int
+	mu   sync.Mutex
+}
+
+// newPortPool creates a pool of the ports from start to end inclusive
+func newPortPool(start, end int) *portPool {
+	pool := &portPool{}
+	for port := end; port >= start; port-- {
+		pool.free = append(pool.free, port)
+	}
+	return pool
+}
+
+// acquire takes a port from the pool, reporting false if none is free
+func (p *portPool) acquire() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.free) == 0 {
+		return 0, false
+	}
+	port := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	return port, true
+}
+
+// release returns a port to the pool
+func (p *portPool) release(port int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, port)
+}
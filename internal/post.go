@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"net/http"
+
+	"fake-traffic-go/config"
+)
+
+// Size of POST bodies when no size distribution is configured
+const defaultPostBodySize = 1024
+
+// nextBody returns the body of the user's next request to a URL given the
+// method, if any: nil for a GET, or for a POST random bytes of a size drawn
+// from the configured distribution. A URL's own method always wins, the POST
+// ratio deciding only for URLs without one.
+func (u *BrowserUser) nextBody(method string) []byte {
+	switch method {
+	case http.MethodGet:
+		return nil
+	case "":
+		if u.postRatio <= 0 || u.rand.Float64() >= u.postRatio {
+			return nil
+		}
+	}
+
+	size := defaultPostBodySize
+	weight := func(s config.PostBodySize) float64 { return s.Weight }
+	if bucket := pickWeighted(u.postSizes, weight, u.rand.Float64()); bucket != nil {
+		size = bucket.MinBytes + u.rand.Intn(bucket.MaxBytes-bucket.MinBytes+1)
+	}
+
+	body := make([]byte, size)
+	u.rand.Read(body)
+	return body
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// proxyServer is a test HTTP proxy answering every request itself and
+// remembering the forwarded source IPs and credentials it saw
+type proxyServer struct {
+	*countingServer
+	mu          sync.Mutex
+	sourceIPs   map[string]bool
+	credentials string
+}
+
+func newProxyServer(t *testing.T) *proxyServer {
+	t.Helper()
+	p := &proxyServer{sourceIPs: make(map[string]bool)}
+	p.countingServer = newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.sourceIPs[r.Header.Get("X-Forwarded-For")] = true
+		if auth, ok := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic "); ok {
+			decoded, _ := base64.StdEncoding.DecodeString(auth)
+			p.credentials = string(decoded)
+		}
+	}))
+	return p
+}
+
+// users returns the source IPs of the users that sent requests through the proxy
+func (p *proxyServer) users() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var users []string
+	for ip := range p.sourceIPs {
+		users = append(users, ip)
+	}
+	return users
+}
+
+func TestUsersGetDistinctProxiesFromPool(t *testing.T) {
+	proxies := []*proxyServer{newProxyServer(t), newProxyServer(t), newProxyServer(t)}
+	list := []string{
+		proxies[0].URL,
+		strings.Replace(proxies[1].URL, "http://", "http://alice:secret@", 1),
+		proxies[2].URL,
+	}
+	listPath := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(listPath, []byte(strings.Join(list, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The target is never resolved, as the proxies answer themselves
+	cfg := newTestConfig(t, "http://target.invalid/")
+	cfg.ProxyListPath = listPath
+	cfg.ConcurrentUsers = 3
+	cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.0", "10.255.255.255"
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "requesting through every proxy", func() bool {
+		for _, proxy := range proxies {
+			if len(proxy.users()) == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	g.Stop()
+
+	// Each user sticks to its own proxy
+	seen := make(map[string]bool)
+	for i, proxy := range proxies {
+		users := proxy.users()
+		if len(users) != 1 || seen[users[0]] {
+			t.Errorf("proxy %d was used by %v, want a single user of its own", i, users)
+		}
+		if len(users) > 0 {
+			seen[users[0]] = true
+		}
+	}
+	if proxies[1].credentials != "alice:secret" {
+		t.Errorf("proxy credentials %q, want alice:secret from the proxy URL", proxies[1].credentials)
+	}
+}
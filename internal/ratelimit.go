@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that limits how often requests may be made.
+// The rate is read on every call, so configuration changes apply immediately.
+type RateLimiter struct {
+	rate   func() int
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewRateLimiter creates a limiter allowing rate() events per second.
+// A rate of zero or less disables limiting.
+func NewRateLimiter(rate func() int) *RateLimiter {
+	return &RateLimiter{
+		rate: rate,
+	}
+}
+
+// Wait blocks until an event is allowed or the context is done.
+// It returns the context's error if it ends before a token became available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			// Try again now that a token should be available
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns
+// how long to wait until the next token is due
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rate := float64(l.rate())
+	if rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	if l.last.IsZero() {
+		l.tokens = 1
+	} else {
+		// Refill, allowing at most one token to accumulate so requests stay evenly paced
+		l.tokens = min(1, l.tokens+now.Sub(l.last).Seconds()*rate)
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,58 @@
+package internal
+
+import "sync"
+
+// ResultSink receives the result of every request made by a generator's users
+type ResultSink interface {
+	Record(result Result)
+}
+
+// RecentResults is a ResultSink keeping the last results in a fixed-size ring
+type RecentResults struct {
+	mu      sync.Mutex
+	results []Result
+	next    int // Position the next result is written to
+	full    bool
+}
+
+// NewRecentResults creates a ring holding the last size results
+func NewRecentResults(size int) *RecentResults {
+	return &RecentResults{results: make([]Result, size)}
+}
+
+// Record implements ResultSink, replacing the oldest result once the ring is full
+func (r *RecentResults) Record(result Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.results) == 0 {
+		return
+	}
+	r.results[r.next] = result
+	r.next = (r.next + 1) % len(r.results)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Recent returns up to n of the most recent results, newest first.
+// An n of 0 or less returns all retained results.
+func (r *RecentResults) Recent(n int) []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.results)
+	}
+	if n > 0 && n < count {
+		count = n
+	}
+
+	recent := make([]Result, 0, count)
+	for i := 1; i <= count; i++ {
+		index := (r.next - i + len(r.results)) % len(r.results)
+		recent = append(recent, r.results[index])
+	}
+	return recent
+}
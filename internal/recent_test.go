@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// urlsOf returns the URLs of the results, in order
+func urlsOf(results []Result) []string {
+	urls := make([]string, len(results))
+	for i, result := range results {
+		urls[i] = result.URL
+	}
+	return urls
+}
+
+func TestRecentResultsHoldsTheLastN(t *testing.T) {
+	ring := NewRecentResults(3)
+	if got := ring.Recent(0); len(got) != 0 {
+		t.Fatalf("empty ring returned %d results", len(got))
+	}
+
+	for i := 1; i <= 2; i++ {
+		ring.Record(Result{URL: fmt.Sprint(i)})
+	}
+	if got := fmt.Sprint(urlsOf(ring.Recent(0))); got != "[2 1]" {
+		t.Errorf("Recent(0) before wrapping = %s, want [2 1]", got)
+	}
+
+	for i := 3; i <= 7; i++ {
+		ring.Record(Result{URL: fmt.Sprint(i)})
+	}
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "[7 6 5]"},
+		{2, "[7 6]"},
+		{10, "[7 6 5]"},
+		{-1, "[7 6 5]"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(urlsOf(ring.Recent(test.n))); got != test.want {
+			t.Errorf("Recent(%d) = %s, want %s", test.n, got, test.want)
+		}
+	}
+}
+
+func TestRecentEndpoint(t *testing.T) {
+	cfg := newTestConfig(t, "https://a.example/")
+	cfg.RecentResultsSize = 2
+	g := newTestGenerator(t, cfg)
+	for i := 1; i <= 3; i++ {
+		g.recordResult(Result{URL: fmt.Sprintf("https://a.example/%d", i), Status: 200})
+	}
+
+	recorder := httptest.NewRecorder()
+	NewControlHandler(g).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recent", nil))
+	var results []struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatalf("GET /recent answered %d %q: %v", recorder.Code, recorder.Body, err)
+	}
+	if len(results) != 2 || results[0].URL != "https://a.example/3" || results[1].URL != "https://a.example/2" {
+		t.Errorf("GET /recent = %+v, want the last 2 results newest first", results)
+	}
+
+	recorder = httptest.NewRecorder()
+	NewControlHandler(g).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/recent?n=-1", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("GET /recent?n=-1 status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
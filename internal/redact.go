@@ -0,0 +1,13 @@
+package internal
+
+import "net/url"
+
+// redactURL masks the password in a URL's userinfo so that credentials are
+// not leaked into logs or results. URLs without credentials are returned as is.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,14 @@
+package internal
+
+import "fake-traffic-go/config"
+
+// entryReferrer draws the referer of a new session from the configured
+// referrers, returning an empty string for direct traffic or when none are set
+func (u *BrowserUser) entryReferrer() string {
+	weight := func(r config.EntryReferrer) float64 { return r.Weight }
+	referrer := pickWeighted(u.referrers, weight, u.rand.Float64())
+	if referrer == nil {
+		return ""
+	}
+	return referrer.URL
+}
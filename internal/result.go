@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Result describes the outcome of a single request
+type Result struct {
+	Timestamp time.Time
+	URL       string
+	Method    string
+	Status    int
+	Proto     string // Protocol of the response, e.g. HTTP/2.0
+	Duration  time.Duration
+	Bytes     int64
+	BytesSent int64 // Size of the request body
+	SourceIP  string
+	Class     string // Class of the user that made the request, if any
+	Campaign  string // Campaign of the URL requested, if any
+	Entry     string // URL file entry the URL was drawn from, the template for a URL template
+	Abandoned bool   // The user gave up before the response completed
+	Truncated bool   // The body was closed at the maximum read duration
+	Invalid   error  // Why the response failed validation, if it did
+	Err       error
+
+	// Set according to the client's body mode
+	BodyHash   string
+	BodySample string
+}
+
+// MarshalJSON encodes the result in the format used for results files
+func (r Result) MarshalJSON() ([]byte, error) {
+	errText := ""
+	if r.Err != nil {
+		errText = r.Err.Error()
+	}
+	validationText := ""
+	if r.Invalid != nil {
+		validationText = r.Invalid.Error()
+	}
+
+	return json.Marshal(struct {
+		Timestamp  time.Time `json:"ts"`
+		URL        string    `json:"url"`
+		Method     string    `json:"method"`
+		Status     int       `json:"status"`
+		Proto      string    `json:"proto,omitempty"`
+		DurationMs float64   `json:"duration_ms"`
+		Bytes      int64     `json:"bytes"`
+		BytesSent  int64     `json:"bytes_sent,omitempty"`
+		SourceIP   string    `json:"source_ip"`
+		Class      string    `json:"class,omitempty"`
+		Campaign   string    `json:"campaign,omitempty"`
+		Abandoned  bool      `json:"abandoned,omitempty"`
+		Truncated  bool      `json:"truncated,omitempty"`
+		Validation string    `json:"validation_error,omitempty"`
+		BodyHash   string    `json:"body_hash,omitempty"`
+		BodySample string    `json:"body_sample,omitempty"`
+		Error      string    `json:"error,omitempty"`
+	}{
+		Timestamp:  r.Timestamp,
+		URL:        redactURL(r.URL),
+		Method:     r.Method,
+		Status:     r.Status,
+		Proto:      r.Proto,
+		DurationMs: float64(r.Duration.Microseconds()) / 1000,
+		Bytes:      r.Bytes,
+		BytesSent:  r.BytesSent,
+		SourceIP:   r.SourceIP,
+		Class:      r.Class,
+		Campaign:   r.Campaign,
+		Abandoned:  r.Abandoned,
+		Truncated:  r.Truncated,
+		Validation: validationText,
+		BodyHash:   r.BodyHash,
+		BodySample: r.BodySample,
+		Error:      errText,
+	})
+}
+
+// ResultWriter appends results to a file as JSON lines.
+// Writes are buffered and flushed periodically and on Close.
+type ResultWriter struct {
+	file     *os.File
+	writer   *bufio.Writer
+	mu       sync.Mutex
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// NewResultWriter opens (or creates) the file at filePath for appending results
+func NewResultWriter(filePath string) (*ResultWriter, error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open results file: %w", err)
+	}
+
+	w := &ResultWriter{
+		file:     file,
+		writer:   bufio.NewWriter(file),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.flushLoop()
+
+	return w, nil
+}
+
+// Write appends a single result as one JSON line
+func (w *ResultWriter) Write(result Result) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.writer.Write(line); err != nil {
+		return err
+	}
+	return w.writer.WriteByte('\n')
+}
+
+// Flush writes any buffered results to the file
+func (w *ResultWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writer.Flush()
+}
+
+// Close flushes remaining results and closes the file
+func (w *ResultWriter) Close() error {
+	close(w.stopChan)
+	<-w.done
+
+	if err := w.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// flushLoop flushes the buffer every second so results reach disk during long runs
+func (w *ResultWriter) flushLoop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				fmt.Printf("Error flushing results file: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+// Buffer size of the channel returned by Results when none is configured
+const defaultResultsChannelSize = 1024
+
+// resultChannel is a ResultSink delivering results on a buffered channel
+type resultChannel struct {
+	results chan Result
+	block   bool // Wait for the consumer instead of dropping results
+	dropped atomic.Int64
+}
+
+// Record implements ResultSink. When the buffer is full the result is dropped,
+// or in blocking mode the request waits until the consumer catches up.
+func (c *resultChannel) Record(result Result) {
+	if c.block {
+		c.results <- result
+		return
+	}
+
+	select {
+	case c.results <- result:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// Results returns a channel receiving the result of every request, so callers
+// can consume them while the generator runs. The channel is buffered according
+// to results_channel_size. Once the buffer is full, results are dropped and
+// counted as results_dropped in the stats, unless results_channel_block is set,
+// in which case users wait for the consumer, slowing down traffic; the consumer
+// must then keep receiving until the channel is closed or Stop will not return.
+//
+// The channel is closed when the generator stops; the next call after that
+// returns a new channel. It should be called before Start so no result is missed.
+func (g *TrafficGenerator) Results() <-chan Result {
+	g.sinksMutex.Lock()
+	defer g.sinksMutex.Unlock()
+
+	if g.channel == nil {
+		size := g.config.ResultsChannelSize
+		if size <= 0 {
+			size = defaultResultsChannelSize
+		}
+		g.channel = &resultChannel{
+			results: make(chan Result, size),
+			block:   g.config.ResultsChannelBlock,
+		}
+		g.sinks = append(g.sinks, g.channel)
+	}
+	return g.channel.results
+}
+
+// closeResults closes the channel returned by Results once no user is left to
+// write to it
+func (g *TrafficGenerator) closeResults() {
+	g.sinksMutex.Lock()
+	defer g.sinksMutex.Unlock()
+
+	if g.channel == nil {
+		return
+	}
+	// Requests finishing now may still be calling the sinks of the old slice
+	g.sinks = slices.DeleteFunc(slices.Clone(g.sinks), func(sink ResultSink) bool { return sink == g.channel })
+	close(g.channel.results)
+	g.channel = nil
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockingResultsConsumedDuringRun(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/page")
+	cfg.ConcurrentUsers = 4
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	cfg.ResultsChannelSize = 1
+	cfg.ResultsChannelBlock = true
+	g := newTestGenerator(t, cfg)
+	results := g.Results()
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave the channel full so users wait on it
+	deadline := time.Now().Add(5 * time.Second)
+	for len(results) < cap(results) {
+		if time.Now().After(deadline) {
+			t.Fatal("results channel not filled within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Neither registering a sink nor reading the stats waits for the consumer
+	added := make(chan struct{})
+	go func() {
+		g.AddResultSink(make(resultSink))
+		g.GetStatsSnapshot()
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddResultSink() waited for users blocked on the results channel")
+	}
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for result := range results {
+			if result.URL != server.URL+"/page" {
+				t.Errorf("result for %s, want %s", result.URL, server.URL+"/page")
+			}
+			count++
+		}
+		received <- count
+	}()
+	waitForRequests(t, g, 10)
+	if dropped := g.GetStatsSnapshot().ResultsDropped; dropped != 0 {
+		t.Errorf("results_dropped = %d, want none in blocking mode", dropped)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		g.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() did not return while the results were being consumed")
+	}
+
+	count := <-received
+	if total := g.GetStatsSnapshot().TotalRequests; int64(count) != total {
+		t.Errorf("consumer received %d results, want all %d requests", count, total)
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"fake-traffic-go/config"
+)
+
+// Conditions under which a failed request may be retried, as used in Config.RetryOn
+const (
+	RetryOnConnectionError = "connection_error"
+	RetryOn5xx             = "5xx"
+	RetryOn429             = "429"
+)
+
+// Conditions retried when MaxRetries is set without RetryOn
+var defaultRetryOn = []string{RetryOnConnectionError, RetryOn5xx}
+
+// Delay between attempts when no retry delay is configured
+const defaultRetryDelay = time.Second
+
+// retryPolicy decides whether and when a failed request is retried
+type retryPolicy struct {
+	maxRetries      int
+	delay           time.Duration
+	connectionError bool
+	serverError     bool
+	tooManyRequests bool
+}
+
+// newRetryPolicy creates the retry policy described by the configuration
+func newRetryPolicy(cfg *config.Config) retryPolicy {
+	retryOn := cfg.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+
+	policy := retryPolicy{
+		maxRetries:      cfg.MaxRetries,
+		delay:           time.Duration(cfg.RetryDelay * float64(time.Second)),
+		connectionError: slices.Contains(retryOn, RetryOnConnectionError),
+		serverError:     slices.Contains(retryOn, RetryOn5xx),
+		tooManyRequests: slices.Contains(retryOn, RetryOn429),
+	}
+	if policy.delay <= 0 {
+		policy.delay = defaultRetryDelay
+	}
+	return policy
+}
+
+// shouldRetry reports whether a request with the given outcome is worth retrying
+func (p retryPolicy) shouldRetry(result Result, err error) bool {
+	switch {
+	case result.Abandoned:
+		return false
+	case err != nil:
+		return p.connectionError
+	case result.Status >= 500:
+		return p.serverError
+	case result.Status == http.StatusTooManyRequests:
+		return p.tooManyRequests
+	}
+	return false
+}
+
+// send requests the URL with the given client, as a gRPC call in gRPC mode, as
+// a POST of body if one is given and as a GET otherwise, retrying failed
+// attempts as configured. Every attempt is reported as a request of its own.
+// A positive timeout limits each attempt instead of the client's timeout.
+func (u *BrowserUser) send(ctx context.Context, client *HTTPClient, url string, body []byte, timeout time.Duration) (Result, error) {
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		var result Result
+		var err error
+		if u.grpcMode {
+			result, err = client.Invoke(attemptCtx, url, u.grpcMessage)
+		} else if body != nil {
+			result, err = client.Post(attemptCtx, url, "application/octet-stream", body)
+		} else {
+			result, err = client.Get(attemptCtx, url)
+		}
+		cancel()
+		if attempt >= u.retry.maxRetries || u.ctx.Err() != nil || !u.retry.shouldRetry(result, err) {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-u.clock.After(u.retry.delay):
+		}
+
+		// Retries count against the generator-wide request rate too
+		if u.limiter != nil && u.limiter.Wait(ctx) != nil {
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetryOnConditions(t *testing.T) {
+	// /status/N answers with status N, /drop closes the connection unanswered
+	var attempts atomic.Int64
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/drop" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		status, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/status/"))
+		w.WriteHeader(status)
+	}))
+
+	tests := []struct {
+		path         string
+		retryOn      []string
+		wantAttempts int64
+	}{
+		{"/status/500", []string{RetryOn5xx}, 3},
+		{"/status/503", nil, 3}, // Retried by default
+		{"/status/500", []string{RetryOn429}, 1},
+		{"/status/404", []string{RetryOnConnectionError, RetryOn5xx, RetryOn429}, 1},
+		{"/status/429", []string{RetryOn429}, 3},
+		{"/status/429", nil, 1},
+		{"/drop", []string{RetryOnConnectionError}, 3},
+		{"/drop", []string{RetryOn5xx}, 1},
+	}
+	for _, test := range tests {
+		cfg := newTestConfig(t, server.URL+"/")
+		cfg.MaxRetries = 2
+		cfg.RetryDelay = 0.001
+		cfg.RetryOn = test.retryOn
+		g := newTestGenerator(t, cfg)
+		if err := g.Start(); err != nil {
+			t.Fatal(err)
+		}
+		user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+
+		attempts.Store(0)
+		user.send(g.ctx, user.client, server.URL+test.path, nil, 0)
+		if n := attempts.Load(); n != test.wantAttempts {
+			t.Errorf("%s retrying on %v: %d attempts, want %d", test.path, test.retryOn, n, test.wantAttempts)
+		}
+		user.client.CloseIdleConnections()
+		g.Stop()
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package internal
+
+import "syscall"
+
+// setReuseAddr is a no-op on platforms without SO_REUSEADDR support
+func setReuseAddr(network, address string, conn syscall.RawConn) error {
+	return nil
+}
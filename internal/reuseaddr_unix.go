@@ -0,0 +1,18 @@
+//go:build unix
+
+package internal
+
+import "syscall"
+
+// setReuseAddr allows a socket to bind a local port that still has
+// connections in TIME_WAIT, so a user's source port can be reused
+func setReuseAddr(network, address string, conn syscall.RawConn) error {
+	var sockErr error
+	err := conn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+
+	"fake-traffic-go/config"
+)
+
+// Spread of lognormal session durations unless configured
+const defaultSessionSigma = 1.0
+
+// sessionMinutes draws a session duration in minutes from the configured
+// distribution, within its bounds if it has any
+func sessionMinutes(d config.SessionDuration, r *rand.Rand) float64 {
+	var minutes float64
+	switch d.Distribution {
+	case "exponential":
+		minutes = r.ExpFloat64() * d.MeanMinutes
+	case "lognormal":
+		sigma := d.Sigma
+		if sigma == 0 {
+			sigma = defaultSessionSigma
+		}
+		// The location giving the configured mean for this spread
+		mu := math.Log(d.MeanMinutes) - sigma*sigma/2
+		minutes = math.Exp(mu + sigma*r.NormFloat64())
+	default:
+		return d.MinMinutes + r.Float64()*(d.MaxMinutes-d.MinMinutes)
+	}
+
+	minutes = max(minutes, d.MinMinutes)
+	if d.MaxMinutes > 0 {
+		minutes = min(minutes, d.MaxMinutes)
+	}
+	return minutes
+}
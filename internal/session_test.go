@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"fake-traffic-go/config"
+)
+
+func TestSessionDurationsFollowDistributionMean(t *testing.T) {
+	tests := []struct {
+		duration config.SessionDuration
+		mean     float64
+	}{
+		{config.SessionDuration{Distribution: "uniform", MinMinutes: 10, MaxMinutes: 30}, 20},
+		{config.SessionDuration{Distribution: "exponential", MeanMinutes: 15}, 15},
+		{config.SessionDuration{Distribution: "lognormal", MeanMinutes: 15, Sigma: 0.5}, 15},
+		{config.SessionDuration{Distribution: "lognormal", MeanMinutes: 15}, 15},
+	}
+	for _, test := range tests {
+		const samples = 20000
+		r := rand.New(rand.NewSource(1))
+		sum := 0.0
+		for i := 0; i < samples; i++ {
+			minutes := sessionMinutes(test.duration, r)
+			if minutes < 0 {
+				t.Fatalf("%+v: drew %v minutes", test.duration, minutes)
+			}
+			sum += minutes
+		}
+		if mean := sum / samples; math.Abs(mean-test.mean) > test.mean*0.05 {
+			t.Errorf("%+v: mean of %d sessions %.2f minutes, want %.0f", test.duration, samples, mean, test.mean)
+		}
+	}
+}
+
+func TestSessionDurationsStayWithinBounds(t *testing.T) {
+	duration := config.SessionDuration{Distribution: "exponential", MinMinutes: 2, MaxMinutes: 40, MeanMinutes: 15}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		if minutes := sessionMinutes(duration, r); minutes < 2 || minutes > 40 {
+			t.Fatalf("drew %v minutes, want 2-40", minutes)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"net/http"
+	"time"
+)
+
+// Most idle connections the shared transport keeps per host. The default of
+// two would close most connections a crowd of users returns to the pool.
+const sharedMaxIdleConnsPerHost = 100
+
+// newSharedTransport creates the transport shared by all users, opening its
+// connections through the dialer, so keep-alive connections to a host are
+// reused by every user instead of each holding its own. A positive idle
+// timeout replaces the default one.
+func newSharedTransport(dialer *Dialer, idleTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	if idleTimeout > 0 {
+		transport.IdleConnTimeout = idleTimeout
+	}
+	transport.MaxIdleConns = 0 // No limit across hosts
+	transport.MaxIdleConnsPerHost = sharedMaxIdleConnsPerHost
+	return transport
+}
@@ -0,0 +1,38 @@
+package internal
+
+import "testing"
+
+// connectionsOfUsers returns the number of connections opened by ten users
+// taking turns to make three requests each
+func connectionsOfUsers(t *testing.T, sharedTransport bool) int64 {
+	t.Helper()
+	srv := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, srv.URL+"/")
+	cfg.SharedTransport = sharedTransport
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id := 0; id < 10; id++ {
+		user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+		for i := 0; i < 3; i++ {
+			if _, err := user.client.Get(g.ctx, srv.URL+"/"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer user.client.CloseIdleConnections()
+	}
+	return srv.connections.Load()
+}
+
+func TestSharedTransportPoolsConnectionsAcrossUsers(t *testing.T) {
+	// A connection can be handed back to the pool only after the next request
+	// has dialed, so allow for a few more than one
+	if n := connectionsOfUsers(t, false); n < 10 {
+		t.Errorf("server saw %d connections without sharing, want at least one per user", n)
+	}
+	if n := connectionsOfUsers(t, true); n > 3 {
+		t.Errorf("server saw %d connections with a shared transport, want the users to reuse them", n)
+	}
+}
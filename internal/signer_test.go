@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"fake-traffic-go/config"
+)
+
+func TestServerValidatesRequestSignature(t *testing.T) {
+	const secret = "s3cret"
+	// The server checks the signature the way the target API would
+	verified := make(chan error, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified <- func() error {
+			timestamp, err := strconv.ParseInt(r.Header.Get("X-Api-Time"), 10, 64)
+			if err != nil {
+				return err
+			}
+			if age := time.Since(time.Unix(timestamp, 0)); age < -time.Minute || age > time.Minute {
+				return fmt.Errorf("timestamp is %s old", age)
+			}
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write([]byte(r.Method + "\n" + r.RequestURI + "\n" + strconv.FormatInt(timestamp, 10) + "\n"))
+			signature, err := hex.DecodeString(r.Header.Get("X-Api-Signature"))
+			if err != nil {
+				return err
+			}
+			if !hmac.Equal(signature, mac.Sum(nil)) {
+				return errors.New("signature does not match")
+			}
+			return nil
+		}()
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.RequestSigning = &config.RequestSigning{Secret: secret, Header: "X-Api-Signature", TimestampHeader: "X-Api-Time"}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	defer user.client.CloseIdleConnections()
+
+	for _, path := range []string{"/orders?id=7&sort=desc", "/upload"} {
+		var body []byte
+		if path == "/upload" {
+			body = []byte("payload")
+		}
+		if _, err := user.send(g.ctx, user.client, server.URL+path, body, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-verified; err != nil {
+			t.Errorf("server rejected the signature of %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package internal
+
+import "time"
+
+// Share of the target rate allowed when a slow start begins
+const slowStartFloor = 0.1
+
+// slowStartRate returns the request rate allowed elapsed into a slow start of
+// the given window, rising linearly from a tenth of target to target and never
+// below one request per second. A target of zero or less is returned unchanged.
+func slowStartRate(target int, elapsed, window time.Duration) int {
+	if target <= 0 || elapsed >= window {
+		return target
+	}
+	progress := max(0, elapsed.Seconds()/window.Seconds())
+	share := slowStartFloor + (1-slowStartFloor)*progress
+	return max(1, int(float64(target)*share))
+}
+
+// requestRate returns the generator-wide request rate, ramping up to the
+// configured rate over the slow start window after each Start. Users pacing
+// themselves to a per-user rate are not limited generator-wide.
+func (g *TrafficGenerator) requestRate() int {
+	if g.config.PerUserRPS > 0 {
+		return 0
+	}
+	target := g.config.GetRequestsPerSecond()
+	window := time.Duration(g.config.SlowStartDuration * float64(time.Second))
+	if window <= 0 {
+		return target
+	}
+	elapsed := g.clock.Now().Sub(time.Unix(0, g.startedAt.Load()))
+	return slowStartRate(target, elapsed, window)
+}
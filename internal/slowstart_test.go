@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowStartRampsRateToTarget(t *testing.T) {
+	cfg := newTestConfig(t, "http://127.0.0.1/")
+	cfg.RequestsPerSecond = 100
+	cfg.SlowStartDuration = 10
+	clock := newFakeClock(time.Date(2026, time.October, 12, 9, 0, 0, 0, time.Local))
+	g := newTestGenerator(t, cfg)
+	g.SetClock(clock)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sample the limiter's rate every second through the window and beyond
+	var rates []int
+	for second := 0; second <= 15; second++ {
+		rates = append(rates, g.limiter.rate())
+		clock.Advance(time.Second)
+	}
+
+	if rates[0] != 10 {
+		t.Errorf("rate at start %d, want a tenth of the target", rates[0])
+	}
+	for i := 1; i < len(rates); i++ {
+		if rates[i] < rates[i-1] {
+			t.Fatalf("rates %v, want them never to fall", rates)
+		}
+	}
+	if rates[5] <= rates[0] || rates[5] >= 100 {
+		t.Errorf("rate halfway through %d, want it between the start and the target", rates[5])
+	}
+	for _, rate := range rates[10:] {
+		if rate != 100 {
+			t.Fatalf("rates %v, want the target held after the window", rates)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package internal
+
+import "sort"
+
+// ActiveUser identifies a running user in a snapshot of the active users
+type ActiveUser struct {
+	ID       int    `json:"id"`
+	SourceIP string `json:"source_ip"`
+}
+
+// publishUsers stores the current set of users for ActiveUsers to read
+// without locking. The users mutex must be held.
+func (g *TrafficGenerator) publishUsers() {
+	users := make([]*BrowserUser, 0, len(g.users))
+	for _, user := range g.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	g.activeUsers.Store(&users)
+}
+
+// ActiveUsers returns the IDs and current source IPs of the active users,
+// ordered by ID. It reads a snapshot taken whenever users are added or removed
+// and never waits for the users mutex, so it doesn't hold up user management.
+func (g *TrafficGenerator) ActiveUsers() []ActiveUser {
+	users := g.activeUsers.Load()
+	if users == nil {
+		return []ActiveUser{}
+	}
+
+	active := make([]ActiveUser, len(*users))
+	for i, user := range *users {
+		active[i] = ActiveUser{ID: user.ID, SourceIP: *user.currentIP.Load()}
+	}
+	return active
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestActiveUsersSnapshotDuringAdjustments(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	const maxUsers = 8
+	done := make(chan struct{})
+	var adjusters sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		adjusters.Add(1)
+		go func(i int) {
+			defer adjusters.Done()
+			for n := 0; n < 50; n++ {
+				g.adjustActiveUsers((i + n) % (maxUsers + 1))
+			}
+		}(i)
+	}
+	go func() {
+		adjusters.Wait()
+		close(done)
+	}()
+
+	// Every snapshot is a whole set of users, ordered by unique ID
+	timeout := time.After(10 * time.Second)
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		case <-timeout:
+			t.Fatal("adjusting users timed out, want snapshots never to block them")
+		default:
+		}
+		users := g.ActiveUsers()
+		if len(users) > maxUsers {
+			t.Fatalf("snapshot of %d users, want at most %d", len(users), maxUsers)
+		}
+		for i, user := range users {
+			if i > 0 && user.ID <= users[i-1].ID {
+				t.Fatalf("snapshot IDs %v, want them unique and ordered", users)
+			}
+			if user.SourceIP == "" {
+				t.Fatalf("user %d has no source IP in the snapshot", user.ID)
+			}
+		}
+	}
+
+	// Snapshots don't wait for the users mutex
+	g.adjustActiveUsers(3)
+	g.usersMutex.Lock()
+	snapshot := make(chan []ActiveUser)
+	go func() { snapshot <- g.ActiveUsers() }()
+	select {
+	case users := <-snapshot:
+		if len(users) != 3 {
+			t.Errorf("snapshot of %d users, want 3", len(users))
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("snapshot blocked on the users mutex")
+	}
+	g.usersMutex.Unlock()
+}
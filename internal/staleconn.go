@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"syscall"
+)
+
+// traceReuse makes the request note in reused whether it was sent over a
+// connection taken from the pool
+func traceReuse(req *http.Request, reused *bool) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { *reused = info.Reused },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// isStaleConnection reports whether a request failed because the target reset
+// a pooled connection on its side, so a new connection will likely succeed
+func isStaleConnection(ctx context.Context, reused bool, err error) bool {
+	return reused && ctx.Err() == nil && errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Number of recent response times the latency percentiles are computed from
+const statsLatencySamples = 1000
+
+// requestStats accumulates request totals since start or the last reset
+type requestStats struct {
+	mu            sync.Mutex
+	totalRequests int64
+	totalErrors   int64
+	totalAbandons int64
+	totalInvalid  int64
+	totalBytes    int64
+	totalSent     int64
+	notModified   int64
+	statusCounts  map[int]int64
+	classCounts   map[string]int64
+	campaigns     map[string]*CampaignTotals
+	protoCounts   map[string]int64
+	durations     []time.Duration // Ring of recent response times
+	histogram     [histogramBuckets]int64
+	nextDuration  int
+}
+
+// Stats is a snapshot of the statistics about the traffic generation
+type Stats struct {
+	ActiveUsers          int
+	TargetUsers          int
+	TargetRequestsPerSec int
+	ActualRequestsPerSec float64
+	URLCount             int
+	Enabled              bool
+
+	// Totals since start or the last reset
+	TotalRequests         int64
+	TotalErrors           int64
+	TotalAbandoned        int64
+	TotalValidationErrors int64
+	TotalBytes            int64
+	TotalBytesSent        int64
+	TotalNotModified      int64
+
+	StatusCodes        map[int]int64
+	RequestsByClass    map[string]int64 // Empty unless users have classes
+	RequestsByCampaign map[string]CampaignTotals
+	HTTPVersions       map[string]int64
+
+	// Percentiles of the recent response times, nil before the first response
+	Latency *LatencyPercentiles
+
+	// Results dropped by a full result channel, -1 without a result channel
+	ResultsDropped int64
+}
+
+// LatencyPercentiles are response time percentiles in milliseconds
+type LatencyPercentiles struct {
+	P50 float64
+	P90 float64
+	P99 float64
+}
+
+// CampaignTotals counts the requests to the URLs of one campaign
+type CampaignTotals struct {
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+}
+
+// newRequestStats creates an empty set of statistics
+func newRequestStats() *requestStats {
+	return &requestStats{
+		statusCounts: make(map[int]int64),
+		classCounts:  make(map[string]int64),
+		campaigns:    make(map[string]*CampaignTotals),
+		protoCounts:  make(map[string]int64),
+	}
+}
+
+// record adds a single request result to the totals
+func (s *requestStats) record(result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totalRequests++
+	s.totalBytes += result.Bytes
+	s.totalSent += result.BytesSent
+	if result.Class != "" {
+		s.classCounts[result.Class]++
+	}
+	if result.Campaign != "" {
+		totals := s.campaigns[result.Campaign]
+		if totals == nil {
+			totals = &CampaignTotals{}
+			s.campaigns[result.Campaign] = totals
+		}
+		totals.Requests++
+		if result.Err != nil {
+			totals.Errors++
+		}
+	}
+	if result.Err != nil {
+		s.totalErrors++
+		return
+	}
+	if result.Abandoned {
+		s.totalAbandons++
+		return
+	}
+	if result.Invalid != nil {
+		s.totalInvalid++
+	}
+	s.statusCounts[result.Status]++
+	s.protoCounts[result.Proto]++
+	if result.Status == http.StatusNotModified {
+		s.notModified++
+	}
+
+	s.histogram[histogramBucket(result.Duration)]++
+	if len(s.durations) < statsLatencySamples {
+		s.durations = append(s.durations, result.Duration)
+	} else {
+		s.durations[s.nextDuration] = result.Duration
+		s.nextDuration = (s.nextDuration + 1) % statsLatencySamples
+	}
+}
+
+// reset zeroes all totals
+func (s *requestStats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totalRequests = 0
+	s.totalErrors = 0
+	s.totalAbandons = 0
+	s.totalInvalid = 0
+	s.totalBytes = 0
+	s.totalSent = 0
+	s.notModified = 0
+	s.statusCounts = make(map[int]int64)
+	s.classCounts = make(map[string]int64)
+	s.campaigns = make(map[string]*CampaignTotals)
+	s.protoCounts = make(map[string]int64)
+	s.durations = nil
+	s.histogram = [histogramBuckets]int64{}
+	s.nextDuration = 0
+}
+
+// fill copies the totals into a stats snapshot
+func (s *requestStats) fill(stats *Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats.TotalRequests = s.totalRequests
+	stats.TotalErrors = s.totalErrors
+	stats.TotalAbandoned = s.totalAbandons
+	stats.TotalValidationErrors = s.totalInvalid
+	stats.TotalBytes = s.totalBytes
+	stats.TotalBytesSent = s.totalSent
+	stats.TotalNotModified = s.notModified
+	stats.StatusCodes = maps.Clone(s.statusCounts)
+	stats.RequestsByClass = maps.Clone(s.classCounts)
+	stats.RequestsByCampaign = make(map[string]CampaignTotals, len(s.campaigns))
+	for campaign, totals := range s.campaigns {
+		stats.RequestsByCampaign[campaign] = *totals
+	}
+	stats.HTTPVersions = maps.Clone(s.protoCounts)
+	if len(s.durations) > 0 {
+		sorted := slices.Clone(s.durations)
+		slices.Sort(sorted)
+		stats.Latency = &LatencyPercentiles{
+			P50: percentileMs(sorted, 0.50),
+			P90: percentileMs(sorted, 0.90),
+			P99: percentileMs(sorted, 0.99),
+		}
+	}
+}
+
+// Map returns the snapshot keyed as in the JSON printed and served by the
+// control API
+func (stats Stats) Map() map[string]any {
+	statusCodes := make(map[string]int64, len(stats.StatusCodes))
+	for status, count := range stats.StatusCodes {
+		statusCodes[strconv.Itoa(status)] = count
+	}
+
+	m := map[string]any{
+		"active_users":            stats.ActiveUsers,
+		"target_users":            stats.TargetUsers,
+		"target_requests_per_sec": stats.TargetRequestsPerSec,
+		"actual_requests_per_sec": stats.ActualRequestsPerSec,
+		"url_count":               stats.URLCount,
+		"enabled":                 stats.Enabled,
+		"total_requests":          stats.TotalRequests,
+		"total_errors":            stats.TotalErrors,
+		"total_abandoned":         stats.TotalAbandoned,
+		"total_validation_errors": stats.TotalValidationErrors,
+		"total_bytes":             stats.TotalBytes,
+		"total_bytes_sent":        stats.TotalBytesSent,
+		"total_not_modified":      stats.TotalNotModified,
+		"status_codes":            statusCodes,
+	}
+	if len(stats.RequestsByClass) > 0 {
+		m["requests_by_class"] = stats.RequestsByClass
+	}
+	if len(stats.RequestsByCampaign) > 0 {
+		m["requests_by_campaign"] = stats.RequestsByCampaign
+	}
+	if len(stats.HTTPVersions) > 0 {
+		m["http_versions"] = stats.HTTPVersions
+	}
+	if stats.Latency != nil {
+		m["latency_ms"] = map[string]float64{
+			"p50": stats.Latency.P50,
+			"p90": stats.Latency.P90,
+			"p99": stats.Latency.P99,
+		}
+	}
+	if stats.ResultsDropped >= 0 {
+		m["results_dropped"] = stats.ResultsDropped
+	}
+	return m
+}
+
+// latencyHistogram returns the response times of all successful requests
+// counted in latency buckets
+func (s *requestStats) latencyHistogram() []HistogramBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return histogram(s.histogram)
+}
+
+// percentileMs returns the given percentile of sorted response times in milliseconds
+func percentileMs(sorted []time.Duration, percentile float64) float64 {
+	index := int(float64(len(sorted)-1) * percentile)
+	return float64(sorted[index].Microseconds()) / 1000
+}
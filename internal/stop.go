@@ -0,0 +1,27 @@
+package internal
+
+// StopReason tells why the generator stopped
+type StopReason string
+
+const (
+	// StopRequested means Stop was called, for example on a shutdown signal
+	StopRequested StopReason = "requested"
+
+	// StopPoolExhausted means host budgets left no URL to select
+	StopPoolExhausted StopReason = "pool_exhausted"
+
+	// StopIdleTimeout means no request succeeded within the idle timeout
+	StopIdleTimeout StopReason = "idle_timeout"
+)
+
+// StopReason returns why the generator stopped itself, or StopRequested if it
+// did not stop itself
+func (g *TrafficGenerator) StopReason() StopReason {
+	select {
+	case <-g.selfStopped:
+		// Set before the channel is closed
+		return g.stopReason
+	default:
+		return StopRequested
+	}
+}
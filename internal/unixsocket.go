@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Scheme of URLs addressing an HTTP server on a unix domain socket, such as
+// http+unix://%2Ftmp%2Fapp.sock/path where the host is the escaped socket path
+const unixSocketScheme = "http+unix://"
+
+// Domain of the placeholder hosts standing in for socket paths in rewritten URLs
+const unixSocketDomain = ".unix.invalid"
+
+// Host header sent to servers on unix domain sockets
+const unixSocketHostHeader = "localhost"
+
+// unixSocketRequestURL rewrites an http+unix URL into an http URL whose host
+// encodes the socket path, so that the transport pools connections per socket
+// and the dialer can recover the path. ok is false for any other URL.
+func unixSocketRequestURL(rawURL string) (requestURL string, ok bool, err error) {
+	if len(rawURL) < len(unixSocketScheme) || !strings.EqualFold(rawURL[:len(unixSocketScheme)], unixSocketScheme) {
+		return rawURL, false, nil
+	}
+
+	socket, rest, _ := strings.Cut(rawURL[len(unixSocketScheme):], "/")
+	path, err := url.PathUnescape(socket)
+	if err != nil || path == "" {
+		return "", true, fmt.Errorf("invalid unix socket URL %s", rawURL)
+	}
+	return "http://" + hex.EncodeToString([]byte(path)) + unixSocketDomain + "/" + rest, true, nil
+}
+
+// unixSocketPath returns the socket path encoded in a dial address produced
+// from a rewritten http+unix URL. ok is false for any other address.
+func unixSocketPath(addr string) (path string, ok bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || !isUnixSocketHost(host) {
+		return "", false
+	}
+	decoded, err := hex.DecodeString(strings.TrimSuffix(host, unixSocketDomain))
+	if err != nil {
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// isUnixSocketHost reports whether the host stands for a unix domain socket
+func isUnixSocketHost(host string) bool {
+	return strings.HasSuffix(host, unixSocketDomain)
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"io"
+)
+
+// Largest chunk of a request body sent at once under an upload limit
+const maxUploadChunk = 16 * 1024
+
+// uploadLimiter caps the rate at which request bodies are sent, across all
+// clients sharing it. A nil limiter does not limit.
+type uploadLimiter struct {
+	chunks *RateLimiter // Paces chunks of the body
+	chunk  int          // Size of a chunk in bytes
+}
+
+// newUploadLimiter creates a limiter allowing bytesPerSecond of request bodies,
+// or returns nil if bytesPerSecond is 0 or less
+func newUploadLimiter(bytesPerSecond int) *uploadLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	// Small enough for a hundred chunks per second so sending stays smooth;
+	// rounding the rate down keeps the throughput under the limit
+	chunk := max(1, min(maxUploadChunk, bytesPerSecond/100))
+	rate := bytesPerSecond / chunk
+	return &uploadLimiter{
+		chunks: NewRateLimiter(func() int { return rate }),
+		chunk:  chunk,
+	}
+}
+
+// reader wraps a request body so that reading it respects the limit
+func (l *uploadLimiter) reader(ctx context.Context, body io.Reader) io.Reader {
+	if l == nil {
+		return body
+	}
+	return &throttledReader{ctx: ctx, body: body, limiter: l}
+}
+
+// throttledReader reads a body one chunk at a time, waiting for the limiter
+// before each chunk
+type throttledReader struct {
+	ctx     context.Context
+	body    io.Reader
+	limiter *uploadLimiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.chunk {
+		p = p[:r.limiter.chunk]
+	}
+	if err := r.limiter.chunks.Wait(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.body.Read(p)
+}
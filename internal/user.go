@@ -1,33 +1,85 @@
 package internal
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"fake-traffic-go/config"
 	"fake-traffic-go/ipspoof"
 	"fake-traffic-go/urls"
 )
 
 // BrowserUser represents a simulated user browsing the web
 type BrowserUser struct {
-	ID          int
-	UserAgent   string
-	SourceIP    string
-	sessionTime float64
-	thinkTime   float64
-	urlManager  *urls.URLManager
-	client      *HTTPClient
-	stopChan    chan struct{}
-	wg          *sync.WaitGroup
-	rand        *rand.Rand
+	ID            int
+	Class         string // Name of the user's class, empty without user classes
+	Device        string // Device class, empty without a mobile ratio
+	HTTPVersion   string // HTTP version spoken, empty without configured versions
+	Locale        string // Language tag, DefaultLocale without configured locales
+	UserAgent     string
+	SourceIP      string
+	currentIP     atomic.Pointer[string] // SourceIP, for reading from other goroutines
+	sessionTime   float64
+	thinkTime     float64
+	latencyFactor float64
+	pageInterval  time.Duration // Time between page view starts at the per-user rate, 0 without one
+	minThink      time.Duration // Floor of every think time, as a safety guard
+	burstSize     int
+	burstCooldown time.Duration
+	burstCount    int
+	maxRequests   int
+	bounce        bool // Leave after the first page view
+	pipelineDepth int
+	rotateIP      bool
+	newVisitor    bool
+	retry         retryPolicy
+	abandonChance float64
+	abandonDelay  time.Duration
+	releaseIdle   bool
+	entryURLs     []string
+	entryAtRoot   bool
+	urlParams     map[string]string
+	onExhausted   func()
+	postRatio     float64
+	postSizes     []config.PostBodySize
+	referrers     []config.EntryReferrer
+	grpcMode      bool
+	grpcMessage   []byte
+	grpcMethods   []string                     // Methods called on the hosts of the URL file, nil to call the URLs as listed
+	templates     map[string]*urls.URLTemplate // Parsed URL templates, nil for invalid ones
+	campaign      string                       // Campaign of the URL being requested
+	entry         string                       // URL file entry of the URL being requested
+	urlManager    *urls.URLManager
+	selector      urls.URLSelector
+	ipSpoofer     *ipspoof.IPSpoofer
+	client        *HTTPClient
+	limiter       *RateLimiter
+	log           *slog.Logger
+	clock         Clock
+	localPort     int       // Bound local port, 0 for none
+	localPorts    *portPool // Pool the local port is returned to
+	ctx           context.Context
+	cancel        context.CancelFunc
+	done          chan struct{}
+	wg            *sync.WaitGroup
+	rand          *rand.Rand
 }
 
 // NewBrowserUser creates a new simulated browser user
 func NewBrowserUser(id int, urlManager *urls.URLManager, ipspoofer *ipspoof.IPSpoofer, wg *sync.WaitGroup, generator *TrafficGenerator) *BrowserUser {
-	source := rand.NewSource(time.Now().UnixNano() + int64(id))
-	r := rand.New(source)
+	seed := time.Now().UnixNano() + int64(id)
+	if generator != nil {
+		seed = generator.nextSeed(id)
+	}
+	r := rand.New(rand.NewSource(seed))
 
 	// Generate random think time (interval between page views) between 1-5 seconds
 	thinkTime := 1.0 + r.Float64()*4.0
@@ -35,24 +87,146 @@ func NewBrowserUser(id int, urlManager *urls.URLManager, ipspoofer *ipspoof.IPSp
 	// Generate random session time between 10-30 minutes
 	sessionTime := 10.0 + r.Float64()*20.0
 
+	user := &BrowserUser{
+		ID:            id,
+		Locale:        DefaultLocale,
+		UserAgent:     ipspoof.GenerateRandomUserAgentFrom(r),
+		sessionTime:   sessionTime,
+		thinkTime:     thinkTime,
+		pipelineDepth: 1,
+		urlManager:    urlManager,
+		selector:      urlManager,
+		ipSpoofer:     ipspoofer,
+		clock:         realClock{},
+		log:           defaultRequestLogger,
+		done:          make(chan struct{}),
+		wg:            wg,
+		rand:          r,
+	}
+	user.setSourceIP(ipspoofer.GetRandomIPFrom(r))
+
 	// Create a callback function that records requests in the generator
-	var requestCallback func()
+	parent := context.Background()
+	var requestCallback func(Result)
 	if generator != nil {
-		requestCallback = generator.RecordRequest
+		parent = generator.ctx
+		requestCallback = func(result Result) {
+			result.Class = user.Class
+			result.Campaign = user.campaign
+			result.Entry = user.entry
+			generator.recordResult(result)
+		}
 	}
+	user.ctx, user.cancel = context.WithCancel(parent)
+	user.client = NewHTTPClient(requestCallback)
 
-	return &BrowserUser{
-		ID:          id,
-		UserAgent:   ipspoof.GenerateRandomUserAgent(),
-		SourceIP:    ipspoofer.GetRandomIP(),
-		sessionTime: sessionTime,
-		thinkTime:   thinkTime,
-		urlManager:  urlManager,
-		client:      NewHTTPClient(requestCallback),
-		stopChan:    make(chan struct{}),
-		wg:          wg,
-		rand:        r,
+	// Pick up the generator-wide settings
+	if generator != nil {
+		user.limiter = generator.limiter
+		user.grpcMethods = generator.grpcMethods
+		user.log = generator.log
+		user.clock = generator.clock
+		user.onExhausted = generator.handlePoolExhausted
+		user.client.setLogger(generator.log)
+		user.client.setDialer(generator.dialer)
+		if generator.transport != nil {
+			user.client.setTransport(generator.transport)
+		}
+		if generator.config.HTTP3 {
+			user.client.setHTTP3(generator.dialer)
+		}
+		user.client.setInflightLimiter(generator.inflight)
+		user.client.setUploadLimiter(generator.upload)
+		user.client.SetDecorators(generator.decorators)
+		user.client.setSigner(generator.signer)
+		user.client.setResponseRules(generator.rules)
+		if generator.localPorts != nil {
+			if port, ok := generator.localPorts.acquire(); ok {
+				user.localPort = port
+				user.localPorts = generator.localPorts
+				user.client.setLocalPort(generator.dialer, port)
+			} else {
+				fmt.Printf("User %d: no free local port, using an ephemeral port\n", id)
+			}
+		}
+		if generator.proxies != nil {
+			user.client.SetProxy(generator.proxies.Next())
+		}
+		// Draw URLs from the user's own source rather than the manager's shared one
+		user.selector = urlManager.WithRand(r)
+		user.applyConfig(generator.config)
 	}
+
+	return user
+}
+
+// applyConfig applies the behaviour settings from the configuration to the user and its client
+func (u *BrowserUser) applyConfig(cfg *config.Config) {
+	u.burstSize = cfg.BurstSize
+	u.burstCooldown = time.Duration(cfg.BurstCooldown * float64(time.Second))
+	u.latencyFactor = cfg.ThinkLatencyFactor
+	u.minThink = time.Duration(cfg.MinThinkTime * float64(time.Second))
+	u.maxRequests = cfg.RequestsPerSession
+	if cfg.SessionDuration != nil {
+		u.sessionTime = sessionMinutes(*cfg.SessionDuration, u.rand)
+	}
+	if class := cfg.PickUserClass(u.rand.Float64()); class != nil {
+		u.Class = class.Name
+		u.thinkTime = class.MinThinkTime + u.rand.Float64()*(class.MaxThinkTime-class.MinThinkTime)
+		u.sessionTime = class.MinSessionMinutes + u.rand.Float64()*(class.MaxSessionMinutes-class.MinSessionMinutes)
+		if class.RequestsPerSession > 0 {
+			u.maxRequests = class.RequestsPerSession
+		}
+	}
+	u.bounce = cfg.BounceRate > 0 && u.rand.Float64() < cfg.BounceRate
+	u.pipelineDepth = max(1, cfg.PipelineDepth)
+	if cfg.PerUserRPS > 0 {
+		u.pageInterval = time.Duration(float64(u.pipelineDepth) / cfg.PerUserRPS * float64(time.Second))
+	}
+	u.rotateIP = cfg.RotateIPPerRequest
+	u.newVisitor = cfg.NewVisitorPerRequest
+	u.retry = newRetryPolicy(cfg)
+	u.abandonChance = cfg.AbandonProbability
+	u.abandonDelay = time.Duration(cfg.AbandonDelay * float64(time.Second))
+	if u.abandonDelay <= 0 {
+		u.abandonDelay = defaultAbandonDelay
+	}
+	u.releaseIdle = cfg.MaxOpenConnections > 0
+	u.entryURLs = cfg.EntryURLs
+	u.entryAtRoot = cfg.EntryAtHostRoot
+	u.urlParams = cfg.URLParams
+	u.postRatio = cfg.PostRatio
+	u.postSizes = cfg.PostBodySizes
+	u.referrers = cfg.EntryReferrers
+	u.grpcMode = cfg.Mode == ModeGRPC
+	u.grpcMessage, _ = base64.StdEncoding.DecodeString(cfg.GRPCMessage)
+
+	u.assignDevice(cfg.MobileRatio)
+	u.assignHTTPVersion(cfg.HTTPVersions)
+	u.assignLocale(cfg.Locales)
+	u.client.SetHeaders(cfg.CustomHeaders)
+	u.client.SetConditionalRequests(cfg.ConditionalRequests)
+	u.client.SetSpoofHeaders(cfg.SpoofHeaders)
+	u.client.SetTimeoutJitter(cfg.TimeoutJitterPercent, u.rand)
+	u.client.SetFaultInjection(cfg.FaultInjectionRate, u.rand)
+	if cfg.RandomizeHeaders {
+		u.client.SetHeaderRandomization(u.rand)
+	}
+	u.client.SetRedirectPolicy(cfg.FollowRedirects, cfg.MaxRedirects, cfg.StripHeadersOnCrossHostRedirect)
+
+	bodyMode := BodyMode(cfg.BodyMode)
+	if bodyMode == "" {
+		bodyMode = BodyDiscard
+	}
+
+	// Responses must be fully read for grouped requests to share a connection
+	if bodyMode == BodyDiscard && u.pipelineDepth > 1 {
+		bodyMode = BodyCount
+	}
+	u.client.SetBodyMode(bodyMode, cfg.BodySampleSize)
+	u.client.SetMaxBodyReadDuration(time.Duration(cfg.MaxBodyReadDuration * float64(time.Second)))
+	u.client.SetIdleConnTimeout(time.Duration(cfg.IdleConnTimeout * float64(time.Second)))
+	u.client.SetStaleConnectionRetry(cfg.RetryStaleConnections)
 }
 
 // Start begins the user's browsing session
@@ -60,59 +234,254 @@ func (u *BrowserUser) Start() {
 	u.wg.Add(1)
 	go func() {
 		defer u.wg.Done()
+		defer close(u.done)
+		defer u.releaseLocalPort()
+		defer u.client.CloseIdleConnections()
 
 		fmt.Printf("User %d started with IP %s and think time %.2fs\n",
 			u.ID, u.SourceIP, u.thinkTime)
 
 		// Set up client with our spoofed IP and user agent
 		u.client.SetUserAgent(u.UserAgent)
-		ipspoof.SetTransport(u.SourceIP)
+		u.client.SetSourceIP(u.SourceIP)
 
-		startTime := time.Now()
+		startTime := u.clock.Now()
 		sessionDuration := time.Duration(u.sessionTime * float64(time.Minute))
+		requestCount := 0
+		prevURL := ""
+		var lastLatency time.Duration
 
 		for {
 			select {
-			case <-u.stopChan:
+			case <-u.ctx.Done():
 				fmt.Printf("User %d stopped\n", u.ID)
 				return
 			default:
 				// Check if session time exceeded
-				if time.Since(startTime) > sessionDuration {
+				if u.clock.Now().Sub(startTime) > sessionDuration {
 					fmt.Printf("User %d session time exceeded\n", u.ID)
 					return
 				}
 
-				// Get a random URL to "browse" to
-				url := u.urlManager.GetRandomURL()
-
-				// Make the request
-				err := u.client.Get(url)
-				if err != nil {
-					fmt.Printf("User %d error requesting %s: %v\n", u.ID, url, err)
+				// Pick the next URL to "browse" to; sessions begin at an entry page
+				var url, referer string
+				if prevURL == "" {
+					url = u.entryURL()
+					referer = u.entryReferrer()
 				} else {
-					fmt.Printf("User %d visited %s\n", u.ID, url)
+					url = u.selector.Next(prevURL)
+				}
+				if url == "" {
+					// Nothing is selectable; let the generator react, then try again
+					if u.onExhausted != nil {
+						u.onExhausted()
+					}
+					select {
+					case <-u.ctx.Done():
+						fmt.Printf("User %d stopped\n", u.ID)
+						return
+					case <-u.clock.After(poolExhaustedInterval):
+					}
+					continue
+				}
+				prevURL = url
+				options := u.urlManager.Options(url)
+				u.campaign = options.Campaign
+				u.entry = url
+				url = u.methodURL(u.expandURL(url))
+				u.client.SetReferer(referer)
+				pageStart := u.clock.Now()
+
+				// Load the page; in pipeline mode this is a group of back-to-back
+				// requests sharing one keep-alive connection
+				for i := 0; i < u.pipelineDepth; i++ {
+					// Respect the generator-wide request rate
+					if u.limiter != nil && u.limiter.Wait(u.ctx) != nil {
+						fmt.Printf("User %d stopped\n", u.ID)
+						return
+					}
+
+					// Simulate a new client behind a rotating NAT
+					if u.rotateIP {
+						u.setSourceIP(u.ipSpoofer.GetRandomIPFrom(u.rand))
+						u.client.SetSourceIP(u.SourceIP)
+					}
+
+					// Make the request, from a cold client when simulating new visitors
+					client := u.client
+					if u.newVisitor {
+						client = u.client.fresh()
+					}
+					requestCtx, cancelRequest := u.requestContext()
+					result, err := u.send(requestCtx, client, url, u.nextBody(options.Method), options.Timeout)
+					cancelRequest()
+					if u.newVisitor {
+						client.CloseIdleConnections()
+					}
+					lastLatency = result.Duration
+					requestCount++
+					if u.ctx.Err() != nil {
+						fmt.Printf("User %d stopped\n", u.ID)
+						return
+					}
+					if errors.Is(err, ErrAbandoned) {
+						u.log.Info(fmt.Sprintf("User %d abandoned %s", u.ID, redactURL(url)))
+					} else if err != nil {
+						u.log.Error(fmt.Sprintf("User %d error requesting %s: %v", u.ID, redactURL(url), err))
+					} else {
+						u.log.Info(fmt.Sprintf("User %d visited %s", u.ID, redactURL(url)))
+					}
+
+					// End the session once the request count is reached
+					if u.maxRequests > 0 && requestCount >= u.maxRequests {
+						fmt.Printf("User %d session request count reached\n", u.ID)
+						return
+					}
 				}
 
-				// Calculate think time with some randomness
-				jitter := u.thinkTime * (0.5 + u.rand.Float64())
-				thinkDuration := time.Duration(jitter * float64(time.Second))
+				// A bouncing visitor leaves after the first page
+				if u.bounce {
+					fmt.Printf("User %d bounced\n", u.ID)
+					return
+				}
+
+				// With a connection cap, don't hold on to a slot while thinking
+				if u.releaseIdle {
+					u.client.CloseIdleConnections()
+				}
 
-				// Wait the think time before next request
+				// Wait the think time before next request, ending the session
+				// when it runs out first rather than thinking past its end
+				thinkDuration := u.nextThinkDuration(lastLatency, u.clock.Now().Sub(pageStart))
+				remaining := sessionDuration - u.clock.Now().Sub(startTime)
+				sessionOver := thinkDuration >= remaining
+				if sessionOver {
+					thinkDuration = max(0, remaining)
+				}
 				select {
-				case <-u.stopChan:
+				case <-u.ctx.Done():
 					return
-				case <-time.After(thinkDuration):
+				case <-u.clock.After(thinkDuration):
 					// Continue to next URL
 				}
+				if sessionOver {
+					fmt.Printf("User %d session time exceeded\n", u.ID)
+					return
+				}
 			}
 		}
 	}()
 }
 
-// Stop halts the user's browsing session
+// setSourceIP changes the user's source IP
+func (u *BrowserUser) setSourceIP(ip string) {
+	u.SourceIP = ip
+	u.currentIP.Store(&ip)
+}
+
+// SetURLSelector replaces the strategy used to pick the next URL. A selector
+// that can be seeded draws from the user's own source.
+// It must be called before Start.
+func (u *BrowserUser) SetURLSelector(selector urls.URLSelector) {
+	if seedable, ok := selector.(urls.SeedableSelector); ok && u.rand != nil {
+		selector = seedable.WithRand(u.rand)
+	}
+	u.selector = selector
+}
+
+// entryURL returns the URL for the first request of a session: one of the
+// configured entry URLs, the root of a selected URL's host, or simply the
+// selected URL when no entry behaviour is configured
+func (u *BrowserUser) entryURL() string {
+	if len(u.entryURLs) > 0 {
+		return u.entryURLs[u.rand.Intn(len(u.entryURLs))]
+	}
+
+	target := u.selector.Next("")
+	if u.entryAtRoot {
+		if parsed, err := url.Parse(target); err == nil && parsed.Host != "" {
+			return parsed.Scheme + "://" + parsed.Host + "/"
+		}
+	}
+	return target
+}
+
+// expandURL fills in the placeholders of a URL template with random values.
+// URLs without placeholders, and invalid templates, are returned unchanged.
+func (u *BrowserUser) expandURL(rawURL string) string {
+	if !urls.IsTemplate(rawURL) {
+		return rawURL
+	}
+
+	template, ok := u.templates[rawURL]
+	if !ok {
+		var err error
+		if template, err = urls.ParseURLTemplate(rawURL, u.urlParams); err != nil {
+			fmt.Printf("User %d: %v\n", u.ID, err)
+		}
+		if u.templates == nil {
+			u.templates = make(map[string]*urls.URLTemplate)
+		}
+		u.templates[rawURL] = template
+	}
+
+	if template == nil {
+		return rawURL
+	}
+	return template.Expand(u.rand)
+}
+
+// nextThinkDuration returns how long to wait before the next request, given
+// the time the page view just made took.
+// At a per-user rate the wait fills the rest of the randomly jittered interval
+// between page views. In burst mode requests are sent back-to-back until the
+// burst is complete, followed by the cooldown; otherwise the think time is
+// randomly jittered and extended in proportion to the latency of the previous
+// response. The result is never shorter than the configured minimum think time.
+func (u *BrowserUser) nextThinkDuration(lastLatency, pageTime time.Duration) time.Duration {
+	return max(u.thinkDuration(lastLatency, pageTime), u.minThink)
+}
+
+// thinkDuration computes the think time for nextThinkDuration before the minimum is applied
+func (u *BrowserUser) thinkDuration(lastLatency, pageTime time.Duration) time.Duration {
+	if u.pageInterval > 0 {
+		// The jitter averages out to the interval over many page views
+		interval := time.Duration(float64(u.pageInterval) * (0.5 + u.rand.Float64()))
+		return max(0, interval-pageTime)
+	}
+
+	if u.burstSize > 0 {
+		u.burstCount++
+		if u.burstCount < u.burstSize {
+			return 0
+		}
+		u.burstCount = 0
+		return u.burstCooldown
+	}
+
+	// Calculate think time with some randomness
+	jitter := u.thinkTime * (0.5 + u.rand.Float64())
+	think := jitter + u.latencyFactor*lastLatency.Seconds()
+	return time.Duration(think * float64(time.Second))
+}
+
+// releaseLocalPort returns the user's local port, if any, to the generator's pool
+func (u *BrowserUser) releaseLocalPort() {
+	if u.localPorts != nil {
+		u.localPorts.release(u.localPort)
+		u.localPorts = nil
+	}
+}
+
+// Stop halts the user's browsing session, aborting any request in flight
+// or wait on the rate limiter. It is safe to call more than once.
 func (u *BrowserUser) Stop() {
-	close(u.stopChan)
+	u.cancel()
+}
+
+// Done returns a channel that is closed once the user's session has ended
+func (u *BrowserUser) Done() <-chan struct{} {
+	return u.done
 }
 
 // SimulatePageNavigation simulates a user clicking links and browsing around a site
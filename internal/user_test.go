@@ -0,0 +1,799 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fake-traffic-go/config"
+)
+
+// collectResults runs the generator with one user until it has made n
+// requests and returns their results, in order
+func collectResults(t *testing.T, cfg *config.Config, n int) []Result {
+	t.Helper()
+	cfg.ConcurrentUsers = 1
+	g := newTestGenerator(t, cfg)
+	sink := make(resultSink, n)
+	g.AddResultSink(sink)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]Result, n)
+	for i := range results {
+		results[i] = sink.nextResult(t)
+	}
+	g.Stop()
+	return results
+}
+
+func TestBurstsAreFollowedByCooldown(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.BurstSize = 3
+	cfg.BurstCooldown = 0.3
+	cfg.MinThinkTime = 0
+	results := collectResults(t, cfg, 7)
+
+	// Requests 1-3 and 4-6 are back-to-back, with a cooldown after each burst
+	for i := 1; i < len(results); i++ {
+		gap := results[i].Timestamp.Sub(results[i-1].Timestamp)
+		if i%3 == 0 && gap < 300*time.Millisecond {
+			t.Errorf("gap before request %d is %s, want the 300ms cooldown", i+1, gap)
+		} else if i%3 != 0 && gap >= 150*time.Millisecond {
+			t.Errorf("gap before request %d is %s, want back-to-back within a burst", i+1, gap)
+		}
+	}
+}
+
+func TestUserIsReplacedAfterRequestsPerSession(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.RequestsPerSession = 2
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.0", "10.255.255.255"
+	results := collectResults(t, cfg, 4)
+
+	// Each visitor makes two requests from its own address
+	if results[0].SourceIP != results[1].SourceIP || results[2].SourceIP != results[3].SourceIP {
+		t.Errorf("source IPs %s %s %s %s, want the same IP within each session",
+			results[0].SourceIP, results[1].SourceIP, results[2].SourceIP, results[3].SourceIP)
+	}
+	if results[1].SourceIP == results[2].SourceIP {
+		t.Errorf("replacement user kept the source IP %s, want a new one", results[1].SourceIP)
+	}
+}
+
+func TestPipelinedRequestsShareOneConnection(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.PipelineDepth = 5
+	results := collectResults(t, cfg, 5)
+
+	if n := server.connections.Load(); n != 1 {
+		t.Errorf("server saw %d connections for a group of 5 requests, want 1", n)
+	}
+	if gap := results[4].Timestamp.Sub(results[0].Timestamp); gap >= time.Second {
+		t.Errorf("group of 5 requests took %s, want them back-to-back without think time", gap)
+	}
+}
+
+func TestRotateIPPerRequestVariesForwardedIP(t *testing.T) {
+	var mu sync.Mutex
+	forwarded := make(map[string]bool)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		forwarded[r.Header.Get("X-Forwarded-For")] = true
+		mu.Unlock()
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.RotateIPPerRequest = true
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	cfg.IPRangeStart, cfg.IPRangeEnd = "10.0.0.0", "10.255.255.255"
+	results := collectResults(t, cfg, 5)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(forwarded) < 4 {
+		t.Errorf("5 requests forwarded %d distinct IPs %v, want a new one per request", len(forwarded), forwarded)
+	}
+	for _, result := range results {
+		if !forwarded[result.SourceIP] {
+			t.Errorf("result source IP %s was not forwarded to the server", result.SourceIP)
+		}
+	}
+}
+
+// sequenceSelector is a URL selector navigating through a fixed sequence of URLs
+type sequenceSelector struct {
+	urls []string
+	next int
+}
+
+func (s *sequenceSelector) Next(prev string) string {
+	url := s.urls[s.next%len(s.urls)]
+	s.next++
+	return url
+}
+
+func TestCustomURLSelectorPicksURLs(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/a", server.URL+"/b", server.URL+"/c")
+	cfg.ConcurrentUsers = 1
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	g.SetURLSelector(&sequenceSelector{urls: []string{server.URL + "/c", server.URL + "/a", server.URL + "/a"}})
+	sink := make(resultSink, 6)
+	g.AddResultSink(sink)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"/c", "/a", "/a", "/c", "/a", "/a"} {
+		if got := sink.nextResult(t).URL; got != server.URL+want {
+			t.Errorf("request %d went to %s, want %s", i+1, got, server.URL+want)
+		}
+	}
+}
+
+func TestSessionsStartAtEntryURL(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	tests := []struct {
+		name      string
+		entryURLs []string
+		atRoot    bool
+		want      string
+	}{
+		{"entry URLs", []string{server.URL + "/home"}, false, server.URL + "/home"},
+		{"host root", nil, true, server.URL + "/"},
+	}
+	for _, test := range tests {
+		cfg := newTestConfig(t, server.URL+"/products/1", server.URL+"/products/2")
+		cfg.EntryURLs = test.entryURLs
+		cfg.EntryAtHostRoot = test.atRoot
+		cfg.PerUserRPS = 50
+		cfg.MinThinkTime = 0
+		results := collectResults(t, cfg, 2)
+
+		// The session makes its first request to the entry page, then drills in
+		if results[0].URL != test.want {
+			t.Errorf("%s: first request went to %s, want %s", test.name, results[0].URL, test.want)
+		}
+		if !strings.Contains(results[1].URL, "/products/") {
+			t.Errorf("%s: second request went to %s, want a listed URL", test.name, results[1].URL)
+		}
+	}
+}
+
+func TestThinkTimeGrowsWithLatency(t *testing.T) {
+	const latency = 100 * time.Millisecond
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Write([]byte("ok"))
+	})
+
+	// averageGap returns the mean time between the requests of a user thinking
+	// for 10ms plus twice the latency of the previous response
+	averageGap := func(handler http.Handler) time.Duration {
+		server := newCountingServer(t, handler)
+		cfg := newTestConfig(t, server.URL+"/")
+		cfg.UserClasses = []config.UserClass{{Name: "fast", Weight: 1, MinThinkTime: 0.01, MaxThinkTime: 0.01, MinSessionMinutes: 10, MaxSessionMinutes: 10}}
+		cfg.ThinkLatencyFactor = 2
+		cfg.MinThinkTime = 0
+		results := collectResults(t, cfg, 4)
+		return results[3].Timestamp.Sub(results[0].Timestamp) / 3
+	}
+
+	// The slow server's gaps hold the latency, plus twice that in think time
+	if gap := averageGap(slowHandler); gap < 250*time.Millisecond {
+		t.Errorf("requests to a server answering in %s were %s apart, want about %s", latency, gap, 3*latency)
+	}
+	if gap := averageGap(okHandler); gap >= latency {
+		t.Errorf("requests to a fast server were %s apart, want well under %s", gap, latency)
+	}
+}
+
+func TestCredentialedURLIsLoggedRedacted(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, strings.Replace(server.URL, "http://", "http://alice:secret@", 1)+"/")
+	cfg.ConcurrentUsers = 1
+	g := newTestGenerator(t, cfg)
+	var out bytes.Buffer
+	g.log = newRequestLogger(&out, 1)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForRequests(t, g, 1)
+	g.Stop()
+
+	logged := out.String()
+	if !strings.Contains(logged, "visited http://alice:xxxxx@") {
+		t.Errorf("request log %q does not show the URL with its password masked", logged)
+	}
+	if strings.Contains(logged, "secret") {
+		t.Errorf("request log %q leaks the password", logged)
+	}
+}
+
+func TestNewVisitorPerRequestReusesNoConnection(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.NewVisitorPerRequest = true
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	collectResults(t, cfg, 4)
+
+	if n := server.connections.Load(); n < 4 {
+		t.Errorf("server saw %d connections for 4 requests, want a new one per request", n)
+	}
+}
+
+func TestUserClassesFollowTheirWeights(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.UserClasses = []config.UserClass{
+		{Name: "power", Weight: 1, MinThinkTime: 0.5, MaxThinkTime: 1, MinSessionMinutes: 30, MaxSessionMinutes: 60},
+		{Name: "casual", Weight: 3, MinThinkTime: 5, MaxThinkTime: 10, MinSessionMinutes: 2, MaxSessionMinutes: 5},
+	}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	const users = 2000
+	power := 0
+	for id := 0; id < users; id++ {
+		user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+		switch user.Class {
+		case "power":
+			power++
+			if user.thinkTime < 0.5 || user.thinkTime > 1 {
+				t.Errorf("power user thinks for %.2fs, want 0.5-1s", user.thinkTime)
+			}
+		case "casual":
+			if user.thinkTime < 5 || user.thinkTime > 10 {
+				t.Errorf("casual user thinks for %.2fs, want 5-10s", user.thinkTime)
+			}
+		default:
+			t.Fatalf("user has class %q, want power or casual", user.Class)
+		}
+	}
+
+	// A quarter of the users, within about five standard deviations
+	if share := float64(power) / users; share < 0.2 || share > 0.3 {
+		t.Errorf("%.1f%% of users are power users, want about 25%%", share*100)
+	}
+}
+
+func TestSpoofHeadersCarryTheSourceIP(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r.Header.Clone():
+		default:
+		}
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.SpoofHeaders = []string{"True-Client-IP", "CF-Connecting-IP", "X-Client-IP"}
+	result := collectResults(t, cfg, 1)[0]
+
+	header := <-received
+	for _, name := range cfg.SpoofHeaders {
+		if got := header.Get(name); got != result.SourceIP {
+			t.Errorf("%s = %q, want the source IP %s", name, got, result.SourceIP)
+		}
+	}
+	if got := header.Get("X-Forwarded-For"); got != "" {
+		t.Errorf("X-Forwarded-For = %q, want it replaced by the configured headers", got)
+	}
+}
+
+func TestPostBodySizesFollowDistribution(t *testing.T) {
+	received := make(chan int64, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		received <- n
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.PostRatio = 1
+	cfg.PostBodySizes = []config.PostBodySize{
+		{Weight: 80, MinBytes: 10, MaxBytes: 100},
+		{Weight: 15, MinBytes: 1000, MaxBytes: 2000},
+		{Weight: 5, MinBytes: 10000, MaxBytes: 20000},
+	}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	defer user.client.CloseIdleConnections()
+
+	const requests = 1000
+	buckets := make([]int, len(cfg.PostBodySizes))
+	for i := 0; i < requests; i++ {
+		result, err := user.send(g.ctx, user.client, server.URL+"/", user.nextBody(""), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := <-received
+		if result.Method != http.MethodPost || result.BytesSent != size {
+			t.Fatalf("result of a %d byte upload is a %s of %d bytes", size, result.Method, result.BytesSent)
+		}
+		for j, bucket := range cfg.PostBodySizes {
+			if size >= int64(bucket.MinBytes) && size <= int64(bucket.MaxBytes) {
+				buckets[j]++
+			}
+		}
+	}
+
+	// Within about five standard deviations of each bucket's share
+	for j, want := range []float64{0.8, 0.15, 0.05} {
+		if share := float64(buckets[j]) / requests; share < want-0.065 || share > want+0.065 {
+			t.Errorf("%.1f%% of bodies are %d-%d bytes, want %.0f%%", share*100,
+				cfg.PostBodySizes[j].MinBytes, cfg.PostBodySizes[j].MaxBytes, want*100)
+		}
+	}
+}
+
+func TestUnixSocketTarget(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- r:
+		default:
+		}
+		w.Write([]byte("ok"))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	cfg := newTestConfig(t, "http+unix://"+url.PathEscape(socket)+"/orders?page=2")
+	result := collectResults(t, cfg, 1)[0]
+
+	if result.Err != nil || result.Status != http.StatusOK || result.Bytes != 2 {
+		t.Fatalf("request over the socket: status %d, %d bytes, error %v", result.Status, result.Bytes, result.Err)
+	}
+	r := <-requests
+	if r.URL.RequestURI() != "/orders?page=2" || r.Host != unixSocketHostHeader {
+		t.Errorf("server received %s with Host %q, want /orders?page=2 with Host %q", r.URL.RequestURI(), r.Host, unixSocketHostHeader)
+	}
+}
+
+func TestMinThinkTimeFloorsGaps(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	for _, mode := range []string{"burst", "per-user rate"} {
+		cfg := newTestConfig(t, server.URL+"/")
+		cfg.RequestsPerSecond = 0
+		cfg.MinThinkTime = 0.2
+		if mode == "burst" {
+			cfg.BurstSize = 1 << 30 // Back-to-back requests
+		} else {
+			cfg.PerUserRPS = 1000 // 1ms between requests
+		}
+		results := collectResults(t, cfg, 4)
+
+		for i := 1; i < len(results); i++ {
+			if gap := results[i].Timestamp.Sub(results[i-1].Timestamp); gap < 200*time.Millisecond {
+				t.Errorf("%s: gap before request %d is %s, want at least the 200ms floor", mode, i+1, gap)
+			}
+		}
+	}
+}
+
+func TestMobileRatioSplitsDevices(t *testing.T) {
+	received := make(chan http.Header, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.MobileRatio = 0.3
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	const users = 2000
+	mobile := 0
+	for id := 0; id < users; id++ {
+		user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+		isMobile := user.Device == DeviceMobile
+		if isMobile {
+			mobile++
+		}
+		if isMobile != strings.Contains(user.UserAgent, "Mobile") {
+			t.Errorf("%s user has user agent %q", user.Device, user.UserAgent)
+		}
+
+		// The first few users show their device in their requests
+		if id < 20 {
+			user.client.SetUserAgent(user.UserAgent)
+			if _, err := user.client.Get(g.ctx, server.URL+"/"); err != nil {
+				t.Fatal(err)
+			}
+			user.client.CloseIdleConnections()
+			header := <-received
+			if want := map[bool]string{true: "?1", false: "?0"}[isMobile]; header.Get("Sec-CH-UA-Mobile") != want {
+				t.Errorf("%s user sent Sec-CH-UA-Mobile %q, want %q", user.Device, header.Get("Sec-CH-UA-Mobile"), want)
+			}
+			if header.Get("User-Agent") != user.UserAgent {
+				t.Errorf("%s user sent user agent %q, want %q", user.Device, header.Get("User-Agent"), user.UserAgent)
+			}
+		}
+	}
+
+	// Within about five standard deviations
+	if share := float64(mobile) / users; share < 0.25 || share > 0.35 {
+		t.Errorf("%.1f%% of users are mobile, want about 30%%", share*100)
+	}
+}
+
+func TestEntryReferrersFollowWeights(t *testing.T) {
+	referers := make(chan string, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case referers <- r.Referer():
+		default:
+		}
+	}))
+
+	// A session's first request carries its entry referer
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.EntryReferrers = []config.EntryReferrer{{URL: "https://www.google.com/", Weight: 1}}
+	collectResults(t, cfg, 1)
+	if first := <-referers; first != "https://www.google.com/" {
+		t.Errorf("first request referer = %q, want the entry referrer", first)
+	}
+
+	cfg.EntryReferrers = []config.EntryReferrer{
+		{URL: "https://www.google.com/", Weight: 6},
+		{URL: "https://www.bing.com/", Weight: 1},
+		{URL: "", Weight: 3}, // Direct traffic
+	}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	const draws = 5000
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		counts[user.entryReferrer()]++
+	}
+	// Within about five standard deviations
+	for _, referrer := range cfg.EntryReferrers {
+		want := referrer.Weight / 10
+		if share := float64(counts[referrer.URL]) / draws; share < want-0.035 || share > want+0.035 {
+			t.Errorf("%.1f%% of sessions come from %q, want %.0f%%", share*100, referrer.URL, want*100)
+		}
+	}
+}
+
+func TestConditionalRequestsRecordNotModified(t *testing.T) {
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("page"))
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ConcurrentUsers = 1
+	cfg.ConditionalRequests = true
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	sink := make(resultSink, 2)
+	g.AddResultSink(sink)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first visit fetches the page, the return visit revalidates it
+	first, second := sink.nextResult(t), sink.nextResult(t)
+	if first.Status != http.StatusOK || second.Status != http.StatusNotModified {
+		t.Fatalf("statuses %d and %d, want 200 then 304", first.Status, second.Status)
+	}
+	if n := g.GetStatsSnapshot().TotalNotModified; n < 1 {
+		t.Errorf("stats count %d responses not modified, want the 304 counted", n)
+	}
+}
+
+func TestHostOverrideDialsPinnedAddress(t *testing.T) {
+	hosts := make(chan string, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case hosts <- r.Host:
+		default:
+		}
+	}))
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// shop.invalid never resolves, so the request only arrives through the override
+	cfg := newTestConfig(t, "http://shop.invalid:"+port+"/")
+	cfg.HostOverrides = map[string]string{"shop.invalid": "127.0.0.1"}
+	result := collectResults(t, cfg, 1)[0]
+
+	if result.Err != nil || result.Status != http.StatusOK {
+		t.Fatalf("request to the overridden host: status %d, error %v", result.Status, result.Err)
+	}
+	if host := <-hosts; host != "shop.invalid:"+port {
+		t.Errorf("Host header = %q, want the original shop.invalid:%s", host, port)
+	}
+}
+
+func TestHTTPVersionsFollowWeights(t *testing.T) {
+	protos := make(chan int, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protos <- r.ProtoMajor
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	trusted := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.HTTPVersions = []config.HTTPVersion{{Version: "1.1", Weight: 7}, {Version: "2", Weight: 3}}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	const users = 2000
+	http2 := 0
+	for id := 0; id < users; id++ {
+		user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+		wantMajor := 1
+		if user.HTTPVersion == HTTPVersion2 {
+			http2++
+			wantMajor = 2
+		}
+
+		// The first few users speak their version to the server
+		if id < 20 {
+			user.client.transport.TLSClientConfig = trusted.Clone()
+			result, err := user.client.Get(g.ctx, server.URL+"/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.client.CloseIdleConnections()
+			if major := <-protos; major != wantMajor {
+				t.Errorf("user assigned HTTP/%s spoke HTTP/%d (result %s)", user.HTTPVersion, major, result.Proto)
+			}
+		}
+	}
+
+	// Within about five standard deviations
+	if share := float64(http2) / users; share < 0.25 || share > 0.35 {
+		t.Errorf("%.1f%% of users speak HTTP/2, want about 30%%", share*100)
+	}
+}
+
+func TestEntryTimeoutAbortsSlowResponse(t *testing.T) {
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	short, normal := server.URL+"/report", server.URL+"/home"
+	cfg := newTestConfig(t, short+" timeout=100ms", normal)
+	cfg.ConcurrentUsers = 1
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	g.SetURLSelector(&sequenceSelector{urls: []string{short, normal}})
+	sink := make(resultSink, 2)
+	g.AddResultSink(sink)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := sink.nextResult(t); result.Err == nil || result.Duration > 250*time.Millisecond {
+		t.Errorf("request with a 100ms timeout took %s with error %v, want it aborted", result.Duration, result.Err)
+	}
+	if result := sink.nextResult(t); result.Err != nil || result.Status != http.StatusOK {
+		t.Errorf("request with the default timeout: status %d, error %v, want the slow response", result.Status, result.Err)
+	}
+}
+
+func TestAnnotatedMethodsOverridePostRatio(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	get, post, mixed := server.URL+"/get", server.URL+"/post", server.URL+"/mixed"
+	cfg := newTestConfig(t, get+" method=GET", post+" method=post", mixed)
+	cfg.ConcurrentUsers = 1
+	cfg.PostRatio = 0.5
+	cfg.BurstSize = 1 << 30
+	cfg.RequestsPerSecond = 0
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	g.SetURLSelector(&sequenceSelector{urls: []string{get, post, mixed}})
+	const requests = 150
+	sink := make(resultSink, requests)
+	g.AddResultSink(sink)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	methods := map[string]map[string]int{get: {}, post: {}, mixed: {}}
+	for i := 0; i < requests; i++ {
+		result := sink.nextResult(t)
+		methods[result.URL][result.Method]++
+	}
+	g.Stop()
+
+	if n := methods[get][http.MethodPost]; n > 0 {
+		t.Errorf("%d POSTs to the URL annotated with GET, want none", n)
+	}
+	if n := methods[post][http.MethodGet]; n > 0 {
+		t.Errorf("%d GETs to the URL annotated with POST, want none", n)
+	}
+	if m := methods[mixed]; m[http.MethodGet] == 0 || m[http.MethodPost] == 0 {
+		t.Errorf("methods of the unannotated URL %v, want the POST ratio's mix", m)
+	}
+}
+
+func TestBounceRateSplitsSessions(t *testing.T) {
+	var requests atomic.Int64
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.BounceRate = 0.4
+	cfg.PerUserRPS = 50
+	cfg.MinThinkTime = 0
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	const users = 2000
+	bounces := 0
+	for id := 0; id < users; id++ {
+		user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+		if user.bounce {
+			bounces++
+		}
+
+		// The first few users show their session type in their requests:
+		// a bounce ends after one page, an engaged user keeps browsing
+		if id < 20 {
+			requests.Store(0)
+			user.Start()
+			if user.bounce {
+				select {
+				case <-user.Done():
+				case <-time.After(5 * time.Second):
+					t.Fatal("bouncing user still browsing")
+				}
+				if n := requests.Load(); n != 1 {
+					t.Errorf("bouncing user made %d requests, want 1", n)
+				}
+			} else {
+				waitUntil(t, "engaged user browsing", func() bool { return requests.Load() >= 3 })
+				user.Stop()
+				<-user.Done()
+			}
+			user.client.CloseIdleConnections()
+		}
+	}
+
+	// Within about five standard deviations
+	if share := float64(bounces) / users; share < 0.345 || share > 0.455 {
+		t.Errorf("%.1f%% of sessions are bounces, want about 40%%", share*100)
+	}
+}
+
+func TestSessionEndsDuringLongThink(t *testing.T) {
+	server := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.SessionDuration = &config.SessionDuration{Distribution: "uniform", MinMinutes: 0.005, MaxMinutes: 0.005} // 300ms
+	cfg.MinThinkTime = 10
+	cfg.PerUserRPS = 50
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	start := time.Now()
+	user.Start()
+	select {
+	case <-user.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session still running, want it ended at its 300ms length rather than after the 10s think time")
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > time.Second {
+		t.Errorf("session lasted %s, want about 300ms", elapsed)
+	}
+	if n := g.GetStatsSnapshot().TotalRequests; n != 1 {
+		t.Errorf("%d requests made, want the one before thinking", n)
+	}
+}
+
+func TestAcceptLanguageRendersQualityValues(t *testing.T) {
+	tests := map[string]string{
+		"fr-FR": "fr-FR,fr;q=0.9,en;q=0.8",
+		"de":    "de,en;q=0.9",
+		"en-GB": "en-GB,en;q=0.9",
+		"en":    "en",
+	}
+	for tag, want := range tests {
+		if got := acceptLanguage(tag, nil); got != want {
+			t.Errorf("acceptLanguage(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestLocalesFollowTheirWeights(t *testing.T) {
+	received := make(chan string, 1)
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Accept-Language")
+	}))
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.Locales = []config.Locale{{Tag: "fr-FR", Weight: 60}, {Tag: "de", Weight: 30}, {Tag: "en-GB", Weight: 10}}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A tag then fallback languages of decreasing quality
+	header := regexp.MustCompile(`^([a-z]{2}(?:-[A-Z]{2})?)((?:,[a-z]{2};q=0\.[1-9])*)$`)
+	quality := regexp.MustCompile(`q=0\.([1-9])`)
+	const users = 2000
+	counts := make(map[string]int)
+	for id := 0; id < users; id++ {
+		user := NewBrowserUser(id, g.urlManager, g.ipSpoofer, &g.wg, g)
+		counts[user.Locale]++
+
+		// The first few users show their locale in their requests
+		if id < 20 {
+			if _, err := user.client.Get(g.ctx, server.URL+"/"); err != nil {
+				t.Fatal(err)
+			}
+			user.client.CloseIdleConnections()
+			value := <-received
+			match := header.FindStringSubmatch(value)
+			if match == nil || match[1] != user.Locale {
+				t.Errorf("%s user sent Accept-Language %q, want the locale with quality-valued fallbacks", user.Locale, value)
+				continue
+			}
+			last := 10
+			for _, q := range quality.FindAllStringSubmatch(match[2], -1) {
+				if n := int(q[1][0] - '0'); n >= last {
+					t.Errorf("Accept-Language %q, want decreasing qualities", value)
+				} else {
+					last = n
+				}
+			}
+		}
+	}
+
+	// Within about five standard deviations of each locale's share
+	for _, locale := range cfg.Locales {
+		want := locale.Weight / 100
+		if share := float64(counts[locale.Tag]) / users; share < want-0.055 || share > want+0.055 {
+			t.Errorf("%.1f%% of users are %s, want %.0f%%", share*100, locale.Tag, want*100)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"fake-traffic-go/config"
+)
+
+// ErrValidationFailed is wrapped by the reason a response failed validation
+var ErrValidationFailed = errors.New("response validation failed")
+
+// Most bytes of a body its rules are checked against
+const maxValidatedBodySize = 1 << 20
+
+// ResponseRules checks responses against a set of expectations
+type ResponseRules struct {
+	status   int
+	contains []byte
+	matches  *regexp.Regexp // nil unless the body must match an expression
+	latency  time.Duration
+	headers  []string
+}
+
+// NewResponseRules compiles the expectations of a validation config
+func NewResponseRules(cfg config.ResponseValidation) (*ResponseRules, error) {
+	rules := &ResponseRules{
+		status:   cfg.Status,
+		contains: []byte(cfg.BodyContains),
+		latency:  time.Duration(cfg.MaxLatency * float64(time.Second)),
+		headers:  cfg.RequiredHeaders,
+	}
+	if cfg.BodyMatches != "" {
+		matches, err := regexp.Compile(cfg.BodyMatches)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body expression: %w", err)
+		}
+		rules.matches = matches
+	}
+	return rules, nil
+}
+
+// needsBody reports whether the rules look at the body, which must then be read
+func (r *ResponseRules) needsBody() bool {
+	return r != nil && (len(r.contains) > 0 || r.matches != nil)
+}
+
+// check returns an error wrapping ErrValidationFailed listing every
+// expectation the response missed, or nil if it met them all. body holds the
+// start of the body if the rules need it.
+func (r *ResponseRules) check(resp *http.Response, duration time.Duration, body []byte) error {
+	var problems []string
+	if r.status != 0 && resp.StatusCode != r.status {
+		problems = append(problems, fmt.Sprintf("status %d, expected %d", resp.StatusCode, r.status))
+	}
+	if len(r.contains) > 0 && !bytes.Contains(body, r.contains) {
+		problems = append(problems, fmt.Sprintf("body does not contain %q", r.contains))
+	}
+	if r.matches != nil && !r.matches.Match(body) {
+		problems = append(problems, fmt.Sprintf("body does not match %q", r.matches))
+	}
+	if r.latency > 0 && duration > r.latency {
+		problems = append(problems, fmt.Sprintf("took %v, limit %v", duration.Round(time.Microsecond), r.latency))
+	}
+	for _, header := range r.headers {
+		if _, ok := resp.Header[http.CanonicalHeaderKey(header)]; !ok {
+			problems = append(problems, fmt.Sprintf("missing header %s", header))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrValidationFailed, strings.Join(problems, ", "))
+}
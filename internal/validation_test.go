@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fake-traffic-go/config"
+)
+
+func TestResponseValidationRecordsFailures(t *testing.T) {
+	server := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(150 * time.Millisecond)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not here"))
+			return
+		}
+		w.Header().Set("X-Cache", "HIT")
+		w.Write([]byte("welcome home"))
+	}))
+	closed := httptest.NewServer(okHandler)
+	closed.Close()
+
+	cfg := newTestConfig(t, server.URL+"/")
+	cfg.ResponseValidation = &config.ResponseValidation{
+		Status:          http.StatusOK,
+		BodyContains:    "welcome",
+		BodyMatches:     `home|garden`,
+		MaxLatency:      0.1,
+		RequiredHeaders: []string{"x-cache"},
+	}
+	g := newTestGenerator(t, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	user := NewBrowserUser(0, g.urlManager, g.ipSpoofer, &g.wg, g)
+	defer user.client.CloseIdleConnections()
+
+	tests := []struct {
+		url      string
+		problems []string // Expectations the response misses, none if valid
+	}{
+		{server.URL + "/", nil},
+		{server.URL + "/slow", []string{"took"}},
+		{server.URL + "/missing", []string{"status 404", `contain "welcome"`, "match", "missing header x-cache"}},
+	}
+	for _, test := range tests {
+		result, err := user.send(g.ctx, user.client, test.url, nil, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", test.url, err)
+		}
+		if test.problems == nil {
+			if result.Invalid != nil {
+				t.Errorf("%s failed validation: %v, want it valid", test.url, result.Invalid)
+			}
+			continue
+		}
+		if !errors.Is(result.Invalid, ErrValidationFailed) {
+			t.Errorf("%s validation error %v, want ErrValidationFailed", test.url, result.Invalid)
+			continue
+		}
+		for _, problem := range test.problems {
+			if !strings.Contains(result.Invalid.Error(), problem) {
+				t.Errorf("%s validation error %q, want it to report %q", test.url, result.Invalid, problem)
+			}
+		}
+	}
+
+	// Transport errors are counted apart from validation errors
+	if _, err := user.send(g.ctx, user.client, closed.URL+"/", nil, 0); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	stats := g.GetStatsSnapshot()
+	if stats.TotalValidationErrors != 2 || stats.TotalErrors != 1 {
+		t.Errorf("%d validation errors and %d errors, want 2 and 1", stats.TotalValidationErrors, stats.TotalErrors)
+	}
+}
@@ -0,0 +1,46 @@
+package internal
+
+import "net/http"
+
+// Most URLs whose validators a client remembers
+const maxCachedValidators = 1000
+
+// validator holds the cache validators of a response
+type validator struct {
+	etag         string
+	lastModified string
+}
+
+// validatorCache remembers the validators of the responses a client received,
+// so repeated requests can be made conditional like a browser with a warm cache
+type validatorCache map[string]validator
+
+// apply makes the request for url conditional if its validators are known
+func (c validatorCache) apply(req *http.Request, url string) {
+	v, ok := c[url]
+	if !ok {
+		return
+	}
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+}
+
+// store remembers the validators of a successful response for url
+func (c validatorCache) store(resp *http.Response, url string) {
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	v := validator{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	if v.etag == "" && v.lastModified == "" {
+		return
+	}
+	if _, ok := c[url]; !ok && len(c) >= maxCachedValidators {
+		return
+	}
+	c[url] = v
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Time allowed for opening the warm-up connections to all hosts
+const warmupTimeout = 30 * time.Second
+
+// warmUp opens the configured number of connections to every host through the
+// shared transport, leaving them idle in its pool for the users to reuse. With
+// a cap on open connections, the warm connections stay within it, as idle
+// connections hold their slots; hosts beyond it are not warmed up.
+func (g *TrafficGenerator) warmUp() {
+	origins := g.urlManager.Origins()
+	perHost := min(g.config.WarmupConnectionsPerHost, sharedMaxIdleConnsPerHost)
+	remaining := g.config.MaxOpenConnections
+
+	ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+	defer cancel()
+
+	opened := 0
+	for _, origin := range origins {
+		count := perHost
+		if g.config.MaxOpenConnections > 0 {
+			count = min(count, remaining)
+			remaining -= count
+		}
+		if count == 0 {
+			fmt.Printf("Not warming up %s, max_open_connections reached\n", origin)
+			continue
+		}
+		opened += warmUpOrigin(ctx, g.transport, origin, count)
+	}
+	fmt.Printf("Warmed up %d connections to %d hosts\n", opened, len(origins))
+}
+
+// warmUpOrigin sends count concurrent requests for the root of an origin and
+// returns the number of connections opened for them. Each body is read and
+// closed as soon as its response arrives; a request finding a connection freed
+// that way still has the connection it was dialing added to the pool.
+// HTTP/2 targets multiplex the requests over a single connection.
+func warmUpOrigin(ctx context.Context, transport http.RoundTripper, origin string, count int) int {
+	var opened atomic.Int64
+	trace := &httptrace.ClientTrace{
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				opened.Add(1)
+			}
+		},
+	}
+	ctx = httptrace.WithClientTrace(ctx, trace)
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/", nil)
+			if err != nil {
+				return
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				fmt.Printf("Warm-up request to %s failed: %v\n", origin, err)
+				return
+			}
+			// Fully read bodies leave their connections open for reuse
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	return int(opened.Load())
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarmupOpensConnectionsPerHost(t *testing.T) {
+	srv := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, srv.URL+"/a", srv.URL+"/b")
+	cfg.SharedTransport = true
+	cfg.WarmupConnectionsPerHost = 8
+	g := newTestGenerator(t, cfg)
+
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.connections.Load(); n != 8 {
+		t.Fatalf("server saw %d connections after warm-up, want 8", n)
+	}
+
+	// The warm connections are pooled for the users
+	client := NewHTTPClient(nil)
+	client.setTransport(g.transport)
+	for i := 0; i < 8; i++ {
+		if _, err := client.Get(g.ctx, srv.URL+"/a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := srv.connections.Load(); n != 8 {
+		t.Errorf("server saw %d connections after requests, want the 8 warm ones", n)
+	}
+}
+
+func TestWarmupStaysWithinMaxOpenConnections(t *testing.T) {
+	srv := newCountingServer(t, okHandler)
+	cfg := newTestConfig(t, srv.URL+"/")
+	cfg.SharedTransport = true
+	cfg.WarmupConnectionsPerHost = 8
+	cfg.MaxOpenConnections = 3
+	g := newTestGenerator(t, cfg)
+
+	start := time.Now()
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Start took %s, warm-up blocked on the connection cap", elapsed)
+	}
+	if n := srv.connections.Load(); n != 3 {
+		t.Errorf("server saw %d connections, want max_open_connections of 3", n)
+	}
+}
@@ -0,0 +1,25 @@
+package internal
+
+// pickWeighted selects an item by weighted draw, where draw is a random
+// number in [0, 1). It returns nil when there are no items.
+func pickWeighted[T any](items []T, weightOf func(T) float64, draw float64) *T {
+	if len(items) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	for _, item := range items {
+		total += weightOf(item)
+	}
+
+	point := draw * total
+	for i := range items {
+		point -= weightOf(items[i])
+		if point < 0 {
+			return &items[i]
+		}
+	}
+
+	// Only reached through floating point rounding
+	return &items[len(items)-1]
+}
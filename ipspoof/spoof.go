@@ -1,7 +1,9 @@
 package ipspoof
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"net"
 	"strings"
@@ -9,95 +11,205 @@ import (
 	"time"
 )
 
+// ErrInvalidIPRange is returned when an IP range cannot be parsed or is empty
+var ErrInvalidIPRange = errors.New("invalid IP range")
+
 // IPSpoofer handles IP address spoofing
 type IPSpoofer struct {
-	startIP net.IP
-	endIP   net.IP
+	primary *ipRange
+	ipv6    *ipRange // Optional IPv6 range for dual-stack traffic
+	v6Ratio float64  // Share of addresses drawn from the IPv6 range
 	mu      sync.Mutex
 	rand    *rand.Rand
 }
 
-// NewIPSpoofer creates a new IP spoofer within the given range
+// ipRange is an inclusive range of IPv4 or IPv6 addresses
+type ipRange struct {
+	start  *big.Int // First address of the range
+	size   *big.Int // Number of addresses in the range
+	length int      // Address length in bytes, 4 for IPv4 and 16 for IPv6
+}
+
+// NewIPSpoofer creates a new IP spoofer within the given range.
+// Both addresses must be of the same family, IPv4 or IPv6.
 func NewIPSpoofer(startIPStr string, endIPStr string) (*IPSpoofer, error) {
-	startIP := net.ParseIP(startIPStr).To4()
+	primary, err := parseRange(startIPStr, endIPStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if primary.size.Cmp(big.NewInt(1)) == 0 {
+		fmt.Printf("Warning: IP range is the single address %s, all traffic will be spoofed from it\n", startIPStr)
+	}
+
+	source := rand.NewSource(time.Now().UnixNano())
+	return &IPSpoofer{
+		primary: primary,
+		rand:    rand.New(source),
+	}, nil
+}
+
+// SetIPv6Range adds an IPv6 range for dual-stack simulation. A share of ratio
+// (0-1) of the addresses is drawn from it, the rest from the primary range.
+func (s *IPSpoofer) SetIPv6Range(startIPStr string, endIPStr string, ratio float64) error {
+	ipv6, err := parseRange(startIPStr, endIPStr)
+	if err != nil {
+		return err
+	}
+	if ipv6.length != net.IPv6len {
+		return fmt.Errorf("%w: %s-%s is not an IPv6 range", ErrInvalidIPRange, startIPStr, endIPStr)
+	}
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("%w: IPv6 ratio %g must be between 0 and 1", ErrInvalidIPRange, ratio)
+	}
+
+	if ipv6.size.Cmp(big.NewInt(1)) == 0 && ratio > 0 {
+		fmt.Printf("Warning: IPv6 range is the single address %s, all IPv6 traffic will be spoofed from it\n", startIPStr)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ipv6 = ipv6
+	s.v6Ratio = ratio
+	return nil
+}
+
+// parseRange parses the addresses bounding a range
+func parseRange(startIPStr string, endIPStr string) (*ipRange, error) {
+	startIP := parseIP(startIPStr)
 	if startIP == nil {
-		return nil, fmt.Errorf("invalid start IP address: %s", startIPStr)
+		return nil, fmt.Errorf("%w: invalid start IP address: %s", ErrInvalidIPRange, startIPStr)
 	}
 
-	endIP := net.ParseIP(endIPStr).To4()
+	endIP := parseIP(endIPStr)
 	if endIP == nil {
-		return nil, fmt.Errorf("invalid end IP address: %s", endIPStr)
+		return nil, fmt.Errorf("%w: invalid end IP address: %s", ErrInvalidIPRange, endIPStr)
+	}
+
+	if len(startIP) != len(endIP) {
+		return nil, fmt.Errorf("%w: start IP (%s) and end IP (%s) must be of the same family", ErrInvalidIPRange, startIPStr, endIPStr)
 	}
 
 	// Ensure startIP <= endIP
-	for i := 0; i < 4; i++ {
-		if startIP[i] > endIP[i] {
-			return nil, fmt.Errorf("start IP (%s) must be less than or equal to end IP (%s)", startIPStr, endIPStr)
-		} else if startIP[i] < endIP[i] {
-			break
-		}
+	start := new(big.Int).SetBytes(startIP)
+	end := new(big.Int).SetBytes(endIP)
+	if start.Cmp(end) > 0 {
+		return nil, fmt.Errorf("%w: start IP (%s) must be less than or equal to end IP (%s)", ErrInvalidIPRange, startIPStr, endIPStr)
 	}
 
-	source := rand.NewSource(time.Now().UnixNano())
-	return &IPSpoofer{
-		startIP: startIP,
-		endIP:   endIP,
-		rand:    rand.New(source),
-	}, nil
+	size := new(big.Int).Sub(end, start)
+	size.Add(size, big.NewInt(1))
+	return &ipRange{start: start, size: size, length: len(startIP)}, nil
+}
+
+// parseIP parses an address into its 4 byte IPv4 or 16 byte IPv6 form
+func parseIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+// RangeSize returns the number of addresses in the range, including the IPv6 range if set
+func (s *IPSpoofer) RangeSize() *big.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := new(big.Int).Set(s.primary.size)
+	if s.ipv6 != nil {
+		size.Add(size, s.ipv6.size)
+	}
+	return size
 }
 
 // GetRandomIP returns a random IP address within the configured range
 func (s *IPSpoofer) GetRandomIP() string {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	r := s.primary
+	if s.ipv6 != nil && s.rand.Float64() < s.v6Ratio {
+		r = s.ipv6
+	}
+	offset := new(big.Int).Rand(s.rand, r.size)
+	s.mu.Unlock()
 
-	// Convert IPs to uint32 for easier random generation
-	startInt := ipToUint32(s.startIP)
-	endInt := ipToUint32(s.endIP)
+	return r.address(offset)
+}
 
-	// Generate random IP in range
-	randomInt := startInt + uint32(s.rand.Int63n(int64(endInt-startInt+1)))
-	randomIP := uint32ToIP(randomInt)
+// GetRandomIPFrom is like GetRandomIP but draws the address from source, so
+// callers with a random source of their own don't contend for the spoofer's
+// and a seeded source reproduces the addresses. source must not be used concurrently.
+func (s *IPSpoofer) GetRandomIPFrom(source *rand.Rand) string {
+	s.mu.Lock()
+	r := s.primary
+	ipv6, v6Ratio := s.ipv6, s.v6Ratio
+	s.mu.Unlock()
 
-	return randomIP.String()
+	if ipv6 != nil && source.Float64() < v6Ratio {
+		r = ipv6
+	}
+	return r.address(new(big.Int).Rand(source, r.size))
 }
 
-// Helper function to convert IP to uint32
-func ipToUint32(ip net.IP) uint32 {
-	ip = ip.To4()
-	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+// address returns the address at offset into the range
+func (r *ipRange) address(offset *big.Int) string {
+	ip := offset.Add(offset, r.start).FillBytes(make([]byte, r.length))
+	return net.IP(ip).String()
 }
 
-// Helper function to convert uint32 to IP
-func uint32ToIP(ipInt uint32) net.IP {
-	return net.IPv4(
-		byte(ipInt>>24),
-		byte(ipInt>>16),
-		byte(ipInt>>8),
-		byte(ipInt),
-	)
+// GenerateRandomMobileUserAgent generates a random user agent string of a
+// mobile browser on Android or iOS
+func GenerateRandomMobileUserAgent() string {
+	return mobileUserAgent(rand.Intn)
 }
 
-// SetTransport modifies the HTTP transport to use a specific source IP (requires root privileges)
-// This is a placeholder - in a real implementation, this would use raw sockets or similar
-// Note: This functionality is limited and might not work without proper OS/networking setup
-func SetTransport(sourceIP string) error {
-	// This is a placeholder. In a real implementation, this would:
-	// 1. Create raw sockets or use platform-specific methods to spoof the source IP
-	// 2. Set up proper routing and packet handling
+// GenerateRandomMobileUserAgentFrom is like GenerateRandomMobileUserAgent but
+// draws from source, so a seeded source reproduces the user agent.
+// source must not be used concurrently.
+func GenerateRandomMobileUserAgentFrom(source *rand.Rand) string {
+	return mobileUserAgent(source.Intn)
+}
 
-	// For demonstration purposes, just log that we're using a specific IP
-	fmt.Printf("Using source IP: %s\n", sourceIP)
+// mobileUserAgent generates a mobile user agent with the random numbers drawn by intn
+func mobileUserAgent(intn func(int) int) string {
+	browsers := []string{
+		"Mozilla/5.0 (Linux; Android %d; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Mobile Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS %d_%d like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/%d.%d Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Android %d; Mobile; rv:%d.0) Gecko/%d.0 Firefox/%d.0",
+	}
 
-	return nil
+	browser := browsers[intn(len(browsers))]
+
+	switch {
+	case strings.Contains(browser, "iPhone"):
+		version := 14 + intn(4)
+		return fmt.Sprintf(browser, version, intn(7), version, intn(7))
+	case strings.Contains(browser, "Firefox"):
+		version := 100 + intn(30)
+		return fmt.Sprintf(browser, 10+intn(5), version, version, version)
+	default:
+		return fmt.Sprintf(browser, 10+intn(5), 100+intn(30), intn(9999), intn(999))
+	}
 }
 
 // GenerateRandomUserAgent generates a random user agent string
 // This helps with making traffic look more realistic
 func GenerateRandomUserAgent() string {
-	source := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(source)
+	return userAgent(rand.Intn)
+}
+
+// GenerateRandomUserAgentFrom is like GenerateRandomUserAgent but draws from
+// source, so a seeded source reproduces the user agent.
+// source must not be used concurrently.
+func GenerateRandomUserAgentFrom(source *rand.Rand) string {
+	return userAgent(source.Intn)
+}
 
+// userAgent generates a desktop user agent with the random numbers drawn by intn
+func userAgent(intn func(int) int) string {
 	browsers := []string{
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Safari/537.36",
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:%d.0) Gecko/20100101 Firefox/%d.0",
@@ -105,18 +217,18 @@ func GenerateRandomUserAgent() string {
 		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Edge/%d.%d.%d.%d",
 	}
 
-	browser := browsers[r.Intn(len(browsers))]
+	browser := browsers[intn(len(browsers))]
 
 	switch {
 	case strings.Contains(browser, "Chrome") && !strings.Contains(browser, "Edge"):
-		return fmt.Sprintf(browser, 70+r.Intn(30), r.Intn(9999), r.Intn(999))
+		return fmt.Sprintf(browser, 70+intn(30), intn(9999), intn(999))
 	case strings.Contains(browser, "Firefox"):
-		return fmt.Sprintf(browser, 70+r.Intn(30), 70+r.Intn(30))
+		return fmt.Sprintf(browser, 70+intn(30), 70+intn(30))
 	case strings.Contains(browser, "Safari") && !strings.Contains(browser, "Chrome"):
-		return fmt.Sprintf(browser, 10+r.Intn(5), r.Intn(9), 12+r.Intn(8), r.Intn(9))
+		return fmt.Sprintf(browser, 10+intn(5), intn(9), 12+intn(8), intn(9))
 	case strings.Contains(browser, "Edge"):
-		return fmt.Sprintf(browser, 70+r.Intn(30), r.Intn(9999), r.Intn(999), 15+r.Intn(10), r.Intn(999), r.Intn(999), r.Intn(999))
+		return fmt.Sprintf(browser, 70+intn(30), intn(9999), intn(999), 15+intn(10), intn(999), intn(999), intn(999))
 	default:
-		return fmt.Sprintf(browser, 70+r.Intn(30), r.Intn(9999), r.Intn(999))
+		return fmt.Sprintf(browser, 70+intn(30), intn(9999), intn(999))
 	}
 }
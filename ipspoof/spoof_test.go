@@ -0,0 +1,144 @@
+package ipspoof
+
+import (
+	"math/big"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+func TestGetRandomIPInRange(t *testing.T) {
+	s, err := NewIPSpoofer("10.0.0.1", "10.0.0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := net.ParseIP("10.0.0.1").To4(), net.ParseIP("10.0.0.4").To4()
+	for i := 0; i < 100; i++ {
+		ip := net.ParseIP(s.GetRandomIP()).To4()
+		if ip == nil || ip[3] < start[3] || ip[3] > end[3] || ip[2] != 0 {
+			t.Fatalf("GetRandomIP() = %v, outside 10.0.0.1-10.0.0.4", ip)
+		}
+	}
+}
+
+func TestRangeSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		v6Start    string // IPv6 range added to the primary one, if set
+		v6End      string
+		want       int64
+	}{
+		{"single IPv4 address", "10.0.0.1", "10.0.0.1", "", "", 1},
+		{"two IPv4 addresses", "10.0.0.1", "10.0.0.2", "", "", 2},
+		{"256 IPv4 addresses", "10.0.0.0", "10.0.0.255", "", "", 256},
+		{"IPv6 /128", "2001:db8::1", "2001:db8::1", "", "", 1},
+		{"IPv6 /120", "2001:db8::", "2001:db8::ff", "", "", 256},
+		{"dual stack", "10.0.0.1", "10.0.0.2", "2001:db8::", "2001:db8::ff", 258},
+		{"dual stack with an IPv6 /128", "10.0.0.1", "10.0.0.1", "2001:db8::1", "2001:db8::1", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewIPSpoofer(test.start, test.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.v6Start != "" {
+				if err := s.SetIPv6Range(test.v6Start, test.v6End, 0.5); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := s.RangeSize(); got.Cmp(big.NewInt(test.want)) != 0 {
+				t.Errorf("RangeSize() = %s, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIPv6RatioSetsFamilyDistribution(t *testing.T) {
+	const draws = 10000
+	for _, ratio := range []float64{0, 0.3, 1} {
+		s, err := NewIPSpoofer("10.0.0.0", "10.0.255.255")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetIPv6Range("2001:db8::", "2001:db8::ffff", ratio); err != nil {
+			t.Fatal(err)
+		}
+
+		ipv6 := 0
+		for i := 0; i < draws; i++ {
+			ip := net.ParseIP(s.GetRandomIP())
+			switch {
+			case ip == nil:
+				t.Fatalf("GetRandomIP() returned an invalid address")
+			case ip.To4() == nil:
+				ipv6++
+			}
+		}
+		// Within about five standard deviations
+		if share := float64(ipv6) / draws; share < ratio-0.025 || share > ratio+0.025 {
+			t.Errorf("IPv6 ratio %g: %.1f%% of addresses are IPv6", ratio, share*100)
+		}
+	}
+}
+
+func TestUserAgentFromSeededSourceIsReproducible(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		desktop := GenerateRandomUserAgentFrom(rand.New(rand.NewSource(seed)))
+		if again := GenerateRandomUserAgentFrom(rand.New(rand.NewSource(seed))); again != desktop {
+			t.Errorf("seed %d: desktop user agents %q and %q differ", seed, desktop, again)
+		}
+		mobile := GenerateRandomMobileUserAgentFrom(rand.New(rand.NewSource(seed)))
+		if again := GenerateRandomMobileUserAgentFrom(rand.New(rand.NewSource(seed))); again != mobile {
+			t.Errorf("seed %d: mobile user agents %q and %q differ", seed, mobile, again)
+		}
+	}
+}
+
+// Baselines on a single CPU of a 2026 cloud VM (go test -bench . -benchmem):
+//
+//	BenchmarkGetRandomIP              161 ns/op    71 B/op   2 allocs/op
+//	BenchmarkGetRandomIPv6            228 ns/op    83 B/op   2 allocs/op
+//	BenchmarkGenerateRandomUserAgent  450 ns/op   127 B/op   2 allocs/op
+//
+// GenerateRandomUserAgent took 11.9 µs and 5.5 KB per call while it seeded a
+// new random source each time.
+
+func BenchmarkGetRandomIP(b *testing.B) {
+	s, err := NewIPSpoofer("10.0.0.1", "10.0.255.254")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.GetRandomIP()
+		}
+	})
+}
+
+func BenchmarkGetRandomIPv6(b *testing.B) {
+	s, err := NewIPSpoofer("10.0.0.1", "10.0.255.254")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := s.SetIPv6Range("2001:db8::1", "2001:db8::ffff:ffff:ffff", 0.5); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.GetRandomIP()
+		}
+	})
+}
+
+func BenchmarkGenerateRandomUserAgent(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			GenerateRandomUserAgent()
+		}
+	})
+}
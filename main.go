@@ -1,107 +1,246 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	"fake-traffic-go/config"
+	"fake-traffic-go/fetch"
 	"fake-traffic-go/internal"
 	"fake-traffic-go/urls"
 )
 
 func main() {
-	// Parse command line flags
-	configFile := flag.String("config", "", "Path to configuration file")
-	users := flag.Int("users", 10, "Number of concurrent users")
-	rps := flag.Int("rps", 50, "Target requests per second")
-	urlFile := flag.String("urls", "urls/urls.txt", "Path to URL list file")
-	createSample := flag.Bool("create-sample", false, "Create a sample URL file if none exists")
-	filterURLs := flag.Bool("filter-urls", false, "Filter URLs to remove unreachable ones")
-	filterTimeout := flag.Int("filter-timeout", 5, "Timeout in seconds when checking URL reachability")
-	filterWorkers := flag.Int("filter-workers", 20, "Number of concurrent workers for URL filtering")
-	filterOutput := flag.String("filter-output", "", "Output file for filtered URLs (defaults to overwriting input file)")
-	skipReachability := flag.Bool("skip-reachability", false, "Skip checking if URLs are reachable (faster but less accurate)")
-	filterOnly := flag.Bool("filter-only", false, "Only filter URLs without starting traffic generation")
-	ipStart := flag.String("ip-start", "192.168.1.1", "Start of IP range")
-	ipEnd := flag.String("ip-end", "192.168.1.254", "End of IP range")
-
-	flag.Parse()
+	command, opts, err := parseArgs(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		os.Exit(exitUsage)
+	}
+
+	cfg, err := loadConfig(opts)
+	exitOnError(err)
+
+	switch command {
+	case commandFilter:
+		runFilter(cfg, opts)
+	case commandSweep:
+		runSweep(cfg, opts)
+	case commandValidate:
+		runValidate(cfg)
+	case commandDoctor:
+		runDoctor(cfg)
+	default:
+		run(cfg, opts)
+	}
+}
 
+// exitOnError reports err and exits with a failure status, if err is not nil
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
+
+// warn reports a problem the generator can work around. In strict mode the
+// problem is returned as an error instead, for the caller to give up on.
+func (opts *options) warn(format string, args ...any) error {
+	if opts.strict {
+		return fmt.Errorf("strict mode: "+format, args...)
+	}
+	fmt.Printf("Warning: "+format+"\n", args...)
+	return nil
+}
+
+// loadConfig creates the configuration from the config file, if given, and the
+// command line flags overriding it. It fails on a configuration the generator
+// cannot run with, and in strict mode on any problem it would warn about.
+func loadConfig(opts *options) (*config.Config, error) {
 	// Create config
-	cfg := config.DefaultConfig
+	cfg := config.NewDefaultConfig()
 
 	// Load from file if specified
-	if *configFile != "" {
-		err := cfg.LoadFromFile(*configFile)
+	if opts.configFile != "" {
+		err := cfg.LoadFromFile(opts.configFile)
 		if err != nil {
-			fmt.Printf("Warning: Failed to load config file: %v\n", err)
+			if err := opts.warn("Failed to load config file: %v", err); err != nil {
+				return nil, err
+			}
 		} else {
-			fmt.Printf("Loaded configuration from %s\n", *configFile)
+			fmt.Printf("Loaded configuration from %s\n", opts.configFile)
 		}
 	}
 
 	// Override with command line arguments if they were provided
 	// We check against default values to determine if flags were explicitly set
-	if *users != 10 {
-		cfg.SetConcurrentUsers(*users)
+	defaults := defaultOptions()
+	if opts.users != defaults.users {
+		cfg.SetConcurrentUsers(opts.users)
+	}
+	if opts.rps != defaults.rps {
+		cfg.SetRequestsPerSecond(opts.rps)
+	}
+	if opts.urlFile != defaults.urlFile {
+		cfg.URLFilePath = opts.urlFile
+	}
+	if opts.ipStart != defaults.ipStart {
+		cfg.IPRangeStart = opts.ipStart
+	}
+	if opts.ipEnd != defaults.ipEnd {
+		cfg.IPRangeEnd = opts.ipEnd
+	}
+	if opts.proxyList != "" {
+		cfg.ProxyListPath = opts.proxyList
+	}
+	if opts.baselineRecord != "" {
+		cfg.BaselineRecordFile = opts.baselineRecord
+	}
+	if opts.baselineCompare != "" {
+		cfg.BaselineCompareFile = opts.baselineCompare
+	}
+	if opts.shardIndex != 0 {
+		cfg.ShardIndex = opts.shardIndex
+	}
+	if opts.shardCount != 0 {
+		cfg.ShardCount = opts.shardCount
+	}
+	if opts.logSampleRate != 0 {
+		cfg.LogSampleRate = opts.logSampleRate
+	}
+	if opts.resultsFile != "" {
+		cfg.ResultsFile = opts.resultsFile
+	}
+	// Catch an IP range mixing address families before it fails deep in the spoofer
+	if err := cfg.Validate(); errors.Is(err, config.ErrIPFamilyMismatch) {
+		startFlag, endFlag := opts.ipStart != defaults.ipStart, opts.ipEnd != defaults.ipEnd
+		if opts.configFile != "" && startFlag != endFlag {
+			return nil, fmt.Errorf("%w; only one end of the range is set by the -ip-start and -ip-end flags, the other comes from the config file, set both flags to override the range", err)
+		}
+		return nil, err
+	}
+	if opts.strict && cfg.IPRangeStart == cfg.IPRangeEnd {
+		// Otherwise only reported by the IP spoofer
+		return nil, opts.warn("IP range is the single address %s, all traffic will be spoofed from it", cfg.IPRangeStart)
+	}
+	return cfg, nil
+}
+
+// runDoctor reports on the environment and configuration, exiting with a
+// failure status if any check failed
+func runDoctor(cfg *config.Config) {
+	failed := false
+	for _, check := range internal.RunDoctor(cfg) {
+		fmt.Printf("[%s] %s: %s\n", strings.ToUpper(string(check.Status)), check.Name, check.Detail)
+		failed = failed || check.Status == internal.CheckFail
+	}
+	if failed {
+		os.Exit(exitFailure)
+	}
+}
+
+// runValidate checks that a generator can be created from the configuration,
+// which loads the URL file, the proxy list and the baseline, and exits
+func runValidate(cfg *config.Config) {
+	generator, err := internal.NewTrafficGenerator(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	fmt.Printf("Configuration is valid, %d URLs loaded from %s\n", generator.GetStatsSnapshot().URLCount, cfg.URLFilePath)
+}
+
+// runFilter filters the URL file and exits
+func runFilter(cfg *config.Config, opts *options) {
+	err := filterURLFile(cfg, opts)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Printf("Error: failed to filter URLs: %v\n", err)
+		os.Exit(exitFailure)
+	}
+}
+
+// filterURLFile removes the invalid and, unless skipped, the unreachable URLs
+// from the URL file as set by the filter flags. It returns context.Canceled if
+// filtering was interrupted, leaving the file unchanged.
+func filterURLFile(cfg *config.Config, opts *options) error {
+	// A remote URL file can't be overwritten
+	outputPath := cfg.URLFilePath
+	if opts.filterOutput != "" {
+		outputPath = opts.filterOutput
 	}
-	if *rps != 50 {
-		cfg.SetRequestsPerSecond(*rps)
+	if fetch.IsRemote(outputPath) {
+		return fmt.Errorf("cannot write filtered URLs to the remote location %s, set -filter-output", outputPath)
 	}
-	if *urlFile != "urls/urls.txt" {
-		cfg.URLFilePath = *urlFile
+
+	options := urls.FilterOptions{
+		Timeout:           opts.filterTimeout,
+		Workers:           opts.filterWorkers,
+		CheckReachability: !opts.skipReachability,
+		ValidateURL:       true,
+		ExcludeDomains:    []string{},
+		AllowProtocols:    opts.protocols(),
+		SortByScore:       opts.filterSortScore,
+		MinScore:          opts.filterMinScore,
+		MaxLineLength:     cfg.MaxURLLength,
+		Force:             opts.filterForce,
 	}
-	if *ipStart != "192.168.1.1" {
-		cfg.IPRangeStart = *ipStart
+
+	// Allow Ctrl+C to abort a long filter run without touching the URL file
+	filterCtx, stopFilter := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopFilter()
+	fmt.Printf("Filtering URLs in %s...\n", cfg.URLFilePath)
+	totalURLs, validURLs, err := urls.FilterURLsFileContext(filterCtx, cfg.URLFilePath, outputPath, options)
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("URL filtering interrupted, URL file left unchanged")
+		return err
+	} else if err != nil {
+		return err
 	}
-	if *ipEnd != "192.168.1.254" {
-		cfg.IPRangeEnd = *ipEnd
+
+	fmt.Printf("URL filtering completed: %d of %d URLs are valid (%.1f%%)\n",
+		validURLs, totalURLs, float64(validURLs)/float64(totalURLs)*100.0)
+	return nil
+}
+
+// run generates traffic until interrupted or the generator stops itself
+func run(cfg *config.Config, opts *options) {
+	// Report on the environment and exit if requested
+	if opts.doctor {
+		runDoctor(cfg)
+		return
 	}
 
 	// Create URL sample file if requested and needed
-	if *createSample {
+	if opts.createSample && fetch.IsRemote(cfg.URLFilePath) {
+		exitOnError(opts.warn("Cannot create a sample URL file at the remote location %s", cfg.URLFilePath))
+	} else if opts.createSample {
 		err := urls.CreateSampleURLFile(cfg.URLFilePath)
 		if err != nil {
-			fmt.Printf("Error creating sample URL file: %v\n", err)
+			exitOnError(opts.warn("Failed to create sample URL file: %v", err))
 		} else {
 			fmt.Printf("Created sample URL file at: %s\n", cfg.URLFilePath)
 		}
 	}
 
 	// Filter URLs if requested
-	if *filterURLs {
-		outputPath := cfg.URLFilePath
-		if *filterOutput != "" {
-			outputPath = *filterOutput
-		}
-
-		options := urls.FilterOptions{
-			Timeout:           *filterTimeout,
-			Workers:           *filterWorkers,
-			CheckReachability: !*skipReachability,
-			ValidateURL:       true,
-			ExcludeDomains:    []string{},
-			AllowProtocols:    []string{"http", "https"},
-		}
-
-		fmt.Printf("Filtering URLs in %s...\n", cfg.URLFilePath)
-		totalURLs, validURLs, err := urls.FilterURLsFile(cfg.URLFilePath, outputPath, options)
-		if err != nil {
-			fmt.Printf("Error filtering URLs: %v\n", err)
-		} else {
-			fmt.Printf("URL filtering completed: %d of %d URLs are valid (%.1f%%)\n",
-				validURLs, totalURLs, float64(validURLs)/float64(totalURLs)*100.0)
-
+	if opts.filterURLs {
+		err := filterURLFile(cfg, opts)
+		if errors.Is(err, context.Canceled) {
+			return
+		} else if err != nil {
+			exitOnError(opts.warn("Failed to filter URLs: %v", err))
+		} else if opts.filterOnly {
 			// Exit after filtering if requested
-			if *filterOnly {
-				fmt.Println("Filter-only mode: exiting without starting traffic generation")
-				return
-			}
+			fmt.Println("Filter-only mode: exiting without starting traffic generation")
+			return
 		}
 	}
 
@@ -109,13 +248,42 @@ func main() {
 	generator, err := internal.NewTrafficGenerator(cfg)
 	if err != nil {
 		fmt.Printf("Error initializing traffic generator: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitFailure)
 	}
 
 	err = generator.Start()
 	if err != nil {
 		fmt.Printf("Error starting traffic generator: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitFailure)
+	}
+
+	// Serve the control API if requested
+	if opts.apiAddr != "" {
+		server := &http.Server{Addr: opts.apiAddr, Handler: internal.NewControlHandler(generator)}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Control API error: %v\n", err)
+			}
+		}()
+		defer server.Close()
+		fmt.Printf("Control API listening on %s\n", opts.apiAddr)
+	}
+
+	// The dashboard view closes on Ctrl+C, which it receives instead of a signal
+	var board *dashboard
+	var view *dashboardView
+	var viewClosed <-chan struct{}
+	if opts.tui {
+		board = &dashboard{}
+		view = newDashboardView()
+		viewClosed = view.Closed()
+	}
+	shutdown := &shutdown{generator: generator, view: view, histogram: opts.histogram, output: os.Stdout}
+
+	// Arm the lifetime watchdog as a safety net for unattended runs
+	if opts.maxLifetime > 0 {
+		watchdog := startWatchdog(opts.maxLifetime, watchdogGracePeriod, shutdown.run, os.Exit)
+		defer watchdog.Stop()
 	}
 
 	// Set up signal handling for graceful shutdown
@@ -124,22 +292,53 @@ func main() {
 
 	fmt.Println("Fake traffic generator running. Press Ctrl+C to stop.")
 
-	// Periodically print statistics
-	statsTicker := time.NewTicker(5 * time.Second)
-	defer statsTicker.Stop()
+	// Periodically print statistics, the first soon after starting
+	statsTimer := newStatsSchedule(opts.statsFirstDelay, statsInterval)
+	defer statsTimer.Stop()
+
+	var statsd *statsdEmitter
+	if opts.statsdAddr != "" {
+		statsd, err = newStatsdEmitter(opts.statsdAddr)
+		if err != nil {
+			exitOnError(opts.warn("%v", err))
+		} else {
+			defer statsd.Close()
+		}
+	}
 
 	// Main loop
 	for {
 		select {
 		case <-sigChan:
 			fmt.Println("\nReceived shutdown signal")
-			generator.Stop()
+			shutdown.run()
 			return
 
-		case <-statsTicker.C:
+		case <-viewClosed:
+			fmt.Println("Dashboard closed, shutting down")
+			shutdown.run()
+			return
+
+		case <-generator.SelfStopped():
+			// Wait for the generator to finish stopping itself
+			shutdown.run()
+			os.Exit(exitCode(generator.StopReason()))
+
+		case <-statsTimer.C():
 			// Print current statistics
-			stats := generator.GetStats()
-			statsJSON, _ := json.MarshalIndent(stats, "", "  ")
+			statsTimer.next()
+			stats := generator.GetStatsSnapshot()
+			if statsd != nil {
+				if err := statsd.emit(stats); err != nil {
+					fmt.Printf("Error sending statistics to StatsD: %v\n", err)
+				}
+			}
+			if board != nil {
+				board.update(stats)
+				view.show(board)
+				continue
+			}
+			statsJSON, _ := json.MarshalIndent(stats.Map(), "", "  ")
 			fmt.Println("Traffic Generator Stats:")
 			fmt.Println(string(statsJSON))
 		}
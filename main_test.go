@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fake-traffic-go/config"
+)
+
+func TestLoadConfigStrictFailsOnBadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, opts, err := parseArgs([]string{"-config", path}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(opts)
+	if err != nil || cfg == nil {
+		t.Fatalf("loadConfig() = %v, %v, want the defaults without -strict", cfg, err)
+	}
+
+	_, opts, err = parseArgs([]string{"-config", path, "-strict"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(opts); err == nil {
+		t.Error("loadConfig() succeeded with -strict on an unreadable config file, want an error")
+	}
+}
+
+func TestLoadConfigReportsIPFamilyConflictWithFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"ip_range_start": "2001:db8::1", "ip_range_end": "2001:db8::ff"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args      []string
+		wantErr   bool
+		wantFlags bool // Whether the error points at the flags
+	}{
+		{[]string{"-config", path}, false, false},
+		{[]string{"-config", path, "-ip-start", "10.0.0.1", "-ip-end", "10.0.0.9"}, false, false},
+		{[]string{"-config", path, "-ip-start", "10.0.0.1"}, true, true},
+		{[]string{"-config", path, "-ip-end", "10.0.0.9"}, true, true},
+		{[]string{"-ip-start", "2001:db8::1", "-ip-end", "10.0.0.9"}, true, false},
+	}
+	for _, test := range tests {
+		_, opts, err := parseArgs(test.args, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = loadConfig(opts)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: loadConfig() error = %v, want error %v", test.args, err, test.wantErr)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, config.ErrIPFamilyMismatch) {
+			t.Errorf("%v: loadConfig() error = %v, want ErrIPFamilyMismatch", test.args, err)
+		}
+		if mentionsFlags := strings.Contains(err.Error(), "-ip-start and -ip-end flags"); mentionsFlags != test.wantFlags {
+			t.Errorf("%v: loadConfig() error %q points at the flags: %v, want %v", test.args, err, mentionsFlags, test.wantFlags)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io"
+	"sync"
+
+	"fake-traffic-go/internal"
+)
+
+// shutdown stops a run and prints its final report. Every way a run ends goes
+// through it before the process exits, whether a signal, the dashboard being
+// closed, the generator stopping itself or the lifetime watchdog.
+type shutdown struct {
+	once      sync.Once
+	generator *internal.TrafficGenerator
+	view      *dashboardView // nil without the dashboard
+	histogram bool
+	output    io.Writer
+}
+
+// run gives the terminal back, stops the generator and prints the histogram
+// if requested. Only the first call does so, later ones wait for it to finish.
+func (s *shutdown) run() {
+	s.once.Do(func() {
+		if s.view != nil {
+			s.view.Stop()
+		}
+		s.generator.Stop()
+		if s.histogram {
+			renderHistogram(s.output, s.generator.LatencyHistogram())
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import "time"
+
+// Interval between statistics prints after the first one
+const statsInterval = 5 * time.Second
+
+// statsSchedule times the statistics prints: the first after its own delay,
+// so that short runs report too, and the following ones at a regular interval
+type statsSchedule struct {
+	timer    *time.Timer
+	interval time.Duration
+}
+
+// newStatsSchedule schedules the first print after first, and every following
+// print interval after the previous one
+func newStatsSchedule(first, interval time.Duration) *statsSchedule {
+	return &statsSchedule{timer: time.NewTimer(max(0, first)), interval: interval}
+}
+
+// C returns the channel receiving a value when the next print is due
+func (s *statsSchedule) C() <-chan time.Time {
+	return s.timer.C
+}
+
+// next schedules the following print; it must be called after each print
+func (s *statsSchedule) next() {
+	s.timer.Reset(s.interval)
+}
+
+// Stop cancels the pending print
+func (s *statsSchedule) Stop() {
+	s.timer.Stop()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// waitTick returns how long the schedule took to tick, failing after a second
+func waitTick(t *testing.T, s *statsSchedule) time.Duration {
+	t.Helper()
+	start := time.Now()
+	select {
+	case <-s.C():
+		return time.Since(start)
+	case <-time.After(time.Second):
+		t.Fatal("no tick within 1s")
+		return 0
+	}
+}
+
+func TestStatsScheduleTicksFirstThenAtInterval(t *testing.T) {
+	const first, interval = 20 * time.Millisecond, 100 * time.Millisecond
+	s := newStatsSchedule(first, interval)
+	defer s.Stop()
+
+	if elapsed := waitTick(t, s); elapsed < first || elapsed >= interval {
+		t.Errorf("first tick after %s, want after %s and well before %s", elapsed, first, interval)
+	}
+	for i := 0; i < 2; i++ {
+		s.next()
+		if elapsed := waitTick(t, s); elapsed < interval {
+			t.Errorf("tick %d after %s, want after %s", i+2, elapsed, interval)
+		}
+	}
+}
+
+func TestStatsScheduleWithoutFirstDelayTicksAtOnce(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	for _, first := range []time.Duration{0, -time.Second} {
+		s := newStatsSchedule(first, interval)
+		if elapsed := waitTick(t, s); elapsed >= interval {
+			t.Errorf("first delay %s: first tick after %s, want at once", first, elapsed)
+		}
+		s.next()
+		if elapsed := waitTick(t, s); elapsed < interval {
+			t.Errorf("first delay %s: second tick after %s, want after %s", first, elapsed, interval)
+		}
+		s.Stop()
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"fake-traffic-go/internal"
+)
+
+// Prefix of the names of the metrics sent to StatsD
+const statsdPrefix = "fake_traffic."
+
+// statsdEmitter sends stats snapshots to a StatsD or DogStatsD server over UDP
+type statsdEmitter struct {
+	conn     net.Conn
+	requests int64 // Totals at the previous emit, counters send the increase
+	errors   int64
+}
+
+// newStatsdEmitter creates an emitter sending to the server at addr (host:port)
+func newStatsdEmitter(addr string) (*statsdEmitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to StatsD server: %w", err)
+	}
+	return &statsdEmitter{conn: conn}, nil
+}
+
+// emit sends the requests and errors since the previous emit as counters, and
+// the active users, request rate and latency percentiles as gauges, in one packet
+func (e *statsdEmitter) emit(stats internal.Stats) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%srequests:%d|c\n", statsdPrefix, counterIncrease(stats.TotalRequests, e.requests))
+	fmt.Fprintf(&b, "%serrors:%d|c\n", statsdPrefix, counterIncrease(stats.TotalErrors, e.errors))
+	fmt.Fprintf(&b, "%sactive_users:%d|g\n", statsdPrefix, stats.ActiveUsers)
+	fmt.Fprintf(&b, "%srequests_per_sec:%g|g\n", statsdPrefix, stats.ActualRequestsPerSec)
+	if latency := stats.Latency; latency != nil {
+		fmt.Fprintf(&b, "%slatency_ms.p50:%g|g\n", statsdPrefix, latency.P50)
+		fmt.Fprintf(&b, "%slatency_ms.p90:%g|g\n", statsdPrefix, latency.P90)
+		fmt.Fprintf(&b, "%slatency_ms.p99:%g|g\n", statsdPrefix, latency.P99)
+	}
+	e.requests, e.errors = stats.TotalRequests, stats.TotalErrors
+
+	_, err := e.conn.Write([]byte(strings.TrimSuffix(b.String(), "\n")))
+	return err
+}
+
+// Close closes the connection to the server
+func (e *statsdEmitter) Close() error {
+	return e.conn.Close()
+}
+
+// counterIncrease returns how much a total grew since the previous value,
+// counting from zero if it went down because the stats were reset
+func counterIncrease(total, previous int64) int64 {
+	if total < previous {
+		return total
+	}
+	return total - previous
+}
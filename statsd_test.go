@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"fake-traffic-go/internal"
+)
+
+// readPacket reads the next packet sent to the listener
+func readPacket(t *testing.T, listener net.PacketConn) string {
+	t.Helper()
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestStatsdEmit(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	emitter, err := newStatsdEmitter(listener.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer emitter.Close()
+
+	stats := internal.Stats{
+		ActiveUsers:          4,
+		ActualRequestsPerSec: 12.5,
+		TotalRequests:        100,
+		TotalErrors:          3,
+		Latency:              &internal.LatencyPercentiles{P50: 10, P90: 20.5, P99: 80},
+	}
+	if err := emitter.emit(stats); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"fake_traffic.requests:100|c",
+		"fake_traffic.errors:3|c",
+		"fake_traffic.active_users:4|g",
+		"fake_traffic.requests_per_sec:12.5|g",
+		"fake_traffic.latency_ms.p50:10|g",
+		"fake_traffic.latency_ms.p90:20.5|g",
+		"fake_traffic.latency_ms.p99:80|g",
+	}, "\n")
+	if got := readPacket(t, listener); got != want {
+		t.Errorf("first packet:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Counters send the increase since the previous emit, latencies only once known
+	stats.TotalRequests, stats.TotalErrors, stats.Latency = 150, 3, nil
+	if err := emitter.emit(stats); err != nil {
+		t.Fatal(err)
+	}
+	want = "fake_traffic.requests:50|c\nfake_traffic.errors:0|c\nfake_traffic.active_users:4|g\nfake_traffic.requests_per_sec:12.5|g"
+	if got := readPacket(t, listener); got != want {
+		t.Errorf("second packet:\n%s\nwant:\n%s", got, want)
+	}
+}
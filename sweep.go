@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"fake-traffic-go/config"
+	"fake-traffic-go/internal"
+)
+
+// runSweep generates traffic with each number of users of -sweep-users in
+// turn, printing the statistics of every step once it has run for
+// -sweep-step. The statistics are reset between steps so that each step is
+// reported on its own.
+func runSweep(cfg *config.Config, opts *options) {
+	// Already checked when parsing the arguments
+	steps, _ := opts.sweepSteps()
+
+	cfg.SetConcurrentUsers(steps[0])
+	generator, err := internal.NewTrafficGenerator(cfg)
+	if err != nil {
+		fmt.Printf("Error initializing traffic generator: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	if err := generator.Start(); err != nil {
+		fmt.Printf("Error starting traffic generator: %v\n", err)
+		os.Exit(exitFailure)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	for i, users := range steps {
+		cfg.SetConcurrentUsers(users)
+		generator.ResetStats()
+		fmt.Printf("Sweep step %d/%d: %d users for %s\n", i+1, len(steps), users, opts.sweepStep)
+
+		stepTimer := time.NewTimer(opts.sweepStep)
+		select {
+		case <-sigChan:
+			stepTimer.Stop()
+			fmt.Println("\nReceived shutdown signal")
+			generator.Stop()
+			return
+
+		case <-generator.SelfStopped():
+			stepTimer.Stop()
+			generator.Stop()
+			os.Exit(exitCode(generator.StopReason()))
+
+		case <-stepTimer.C:
+		}
+
+		statsJSON, _ := json.MarshalIndent(generator.GetStats(), "", "  ")
+		fmt.Printf("Sweep step %d/%d stats:\n%s\n", i+1, len(steps), statsJSON)
+	}
+
+	generator.Stop()
+}
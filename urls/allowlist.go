@@ -0,0 +1,80 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrTargetNotAllowed is returned when a URL file targets a host outside the allowlist
+var ErrTargetNotAllowed = errors.New("target not in allowlist")
+
+// targetAllowlist holds the hosts and networks URLs may target
+type targetAllowlist struct {
+	hosts    []string
+	networks []*net.IPNet
+}
+
+// SetAllowlist restricts LoadFromFile to URLs targeting the given hosts and
+// networks; a file with any other URL is refused. Entries are host names, which
+// also allow their subdomains, or CIDR blocks matched against IP address hosts
+// (host names are not resolved). Unix domain sockets are always allowed.
+// An empty list allows every target.
+func (m *URLManager) SetAllowlist(entries []string) error {
+	var allowlist *targetAllowlist
+	if len(entries) > 0 {
+		allowlist = &targetAllowlist{}
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return fmt.Errorf("invalid allowlist entry: %w", err)
+			}
+			allowlist.networks = append(allowlist.networks, network)
+			continue
+		}
+		allowlist.hosts = append(allowlist.hosts, strings.ToLower(strings.TrimSuffix(entry, ".")))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allowlist = allowlist
+	return nil
+}
+
+// allows reports whether the URL targets a host on the allowlist.
+// A nil allowlist allows every URL.
+func (a *targetAllowlist) allows(rawURL string) bool {
+	if a == nil {
+		return true
+	}
+
+	if strings.HasPrefix(rawURL, "http+unix://") {
+		return true
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "" {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range a.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, allowed := range a.hosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package urls
+
+import (
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// SetHostBudget caps the number of requests recorded for any single host.
+// Once a host reaches the budget its URLs are no longer selected.
+// A budget of 0 disables the limit.
+func (m *URLManager) SetHostBudget(budget int) {
+	m.hostBudget.Store(int64(budget))
+}
+
+// RecordHostRequest counts a request sent to the host of the given URL.
+// Requests are only counted while a host budget is set. It takes no lock, as
+// it is called for every request.
+func (m *URLManager) RecordHostRequest(rawURL string) {
+	if m.hostBudget.Load() <= 0 {
+		return
+	}
+	host := hostOf(rawURL)
+	counter, ok := m.hostCounts.Load(host)
+	if !ok {
+		counter, _ = m.hostCounts.LoadOrStore(host, new(atomic.Int64))
+	}
+	counter.(*atomic.Int64).Add(1)
+}
+
+// hostExhausted reports whether the host of the URL at index has used up the given budget.
+// The caller must hold the mutex.
+func (m *URLManager) hostExhausted(index int, budget int64) bool {
+	counter, ok := m.hostCounts.Load(m.hosts[index])
+	return ok && counter.(*atomic.Int64).Load() >= budget
+}
+
+// selectable reports whether the URL at index may be selected: its host has
+// budget left and its campaign is not disabled. The caller must hold the mutex.
+func (m *URLManager) selectable(index int) bool {
+	budget := m.hostBudget.Load()
+	return !(budget > 0 && m.hostExhausted(index, budget)) && !m.campaignDisabled(index)
+}
+
+// randomSelectable picks a random selectable URL.
+// The caller must hold the mutex.
+func (m *URLManager) randomSelectable(r *rand.Rand) (int, bool) {
+	// A few random attempts are usually enough while most URLs are selectable
+	for attempt := 0; attempt < 8; attempt++ {
+		index := r.Intn(len(m.urls))
+		if m.selectable(index) {
+			return index, true
+		}
+	}
+
+	// Fall back to choosing among the remaining URLs
+	var available []int
+	for index := range m.urls {
+		if m.selectable(index) {
+			available = append(available, index)
+		}
+	}
+	if len(available) == 0 {
+		return 0, false
+	}
+	return available[r.Intn(len(available))], true
+}
+
+// hostOf returns the lower-cased host of a URL, or the URL itself if it can't be parsed
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+// ResetHostBudgets forgets the requests recorded for every host,
+// making all URLs selectable again
+func (m *URLManager) ResetHostBudgets() {
+	m.hostCounts.Range(func(_, counter any) bool {
+		counter.(*atomic.Int64).Store(0)
+		return true
+	})
+}
+
+// Hosts returns the distinct host names of the loaded URLs, without ports
+func (m *URLManager) Hosts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, host := range m.hosts {
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			host = name
+		}
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Origins returns the distinct origins, such as https://example.com:8443,
+// of the loaded http and https URLs
+func (m *URLManager) Origins() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var origins []string
+	for _, rawURL := range m.urls {
+		parsed, err := url.Parse(rawURL)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		scheme := strings.ToLower(parsed.Scheme)
+		if scheme != "http" && scheme != "https" {
+			continue
+		}
+		origin := scheme + "://" + strings.ToLower(parsed.Host)
+		if !seen[origin] {
+			seen[origin] = true
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
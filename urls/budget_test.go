@@ -0,0 +1,40 @@
+package urls
+
+import "testing"
+
+func TestHostBudgetExcludesExhaustedHosts(t *testing.T) {
+	m := loadURLs(t, "https://a.example/1", "https://a.example/2", "https://b.example/")
+	m.SetHostBudget(3)
+
+	for i := 0; i < 3; i++ {
+		m.RecordHostRequest("https://a.example/1")
+	}
+	for i := 0; i < 100; i++ {
+		if url := m.GetRandomURL(); url != "https://b.example/" {
+			t.Fatalf("GetRandomURL() = %q, want only the host with budget left", url)
+		}
+	}
+
+	m.RecordHostRequest("https://b.example/")
+	m.RecordHostRequest("https://b.example/")
+	m.RecordHostRequest("https://b.example/")
+	if url := m.GetRandomURL(); url != "" {
+		t.Fatalf("GetRandomURL() = %q with every host exhausted, want none", url)
+	}
+
+	m.ResetHostBudgets()
+	if url := m.GetRandomURL(); url == "" {
+		t.Fatal("no URL selectable after resetting the budgets")
+	}
+}
+
+func TestHostRequestsAreNotCountedWithoutBudget(t *testing.T) {
+	m := loadURLs(t, "https://a.example/")
+	for i := 0; i < 5; i++ {
+		m.RecordHostRequest("https://a.example/")
+	}
+	m.SetHostBudget(1)
+	if url := m.GetRandomURL(); url != "https://a.example/" {
+		t.Fatalf("GetRandomURL() = %q, requests made before the budget was set count against it", url)
+	}
+}
@@ -0,0 +1,86 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnknownCampaign is returned for a campaign no loaded URL belongs to
+var ErrUnknownCampaign = errors.New("unknown campaign")
+
+// Campaign describes a group of URLs tagged with the campaign option
+type Campaign struct {
+	Name    string `json:"name"`
+	URLs    int    `json:"urls"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Campaigns returns the campaigns of the loaded URLs, sorted by name
+func (m *URLManager) Campaigns() []Campaign {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, campaign := range m.campaigns {
+		if campaign != "" {
+			counts[campaign]++
+		}
+	}
+
+	campaigns := make([]Campaign, 0, len(counts))
+	for name, count := range counts {
+		campaigns = append(campaigns, Campaign{Name: name, URLs: count, Enabled: !m.disabled[name]})
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].Name < campaigns[j].Name })
+	return campaigns
+}
+
+// SetCampaignEnabled enables or disables the selection of a campaign's URLs.
+// The setting is kept when the URL file is reloaded. URLs without a campaign
+// are always selectable.
+func (m *URLManager) SetCampaignEnabled(name string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	known := false
+	for _, campaign := range m.campaigns {
+		if campaign == name && name != "" {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("%w: %q", ErrUnknownCampaign, name)
+	}
+
+	if enabled {
+		delete(m.disabled, name)
+	} else {
+		m.disabled[name] = true
+	}
+	return nil
+}
+
+// CampaignsDisabled reports whether every loaded URL belongs to a disabled
+// campaign, leaving nothing to select until one is enabled
+func (m *URLManager) CampaignsDisabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.disabled) == 0 {
+		return false
+	}
+	for _, campaign := range m.campaigns {
+		if !m.disabled[campaign] {
+			return false
+		}
+	}
+	return true
+}
+
+// campaignDisabled reports whether the URL at index belongs to a disabled
+// campaign. The caller must hold the mutex.
+func (m *URLManager) campaignDisabled(index int) bool {
+	return len(m.disabled) > 0 && m.disabled[m.campaigns[index]]
+}
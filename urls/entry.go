@@ -0,0 +1,80 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEntry is returned for a URL file line with malformed options
+var ErrInvalidEntry = errors.New("invalid URL entry")
+
+// EntryOptions are settings given to a URL in the URL file, as space-separated
+// key=value pairs following it, e.g. "https://example.com/report timeout=30s"
+type EntryOptions struct {
+	// Time limit of each request to the URL, replacing the client's timeout (0 keeps it)
+	Timeout time.Duration
+
+	// Method of every request to the URL, "GET" or "POST", instead of the
+	// configured mix of methods (empty keeps the mix)
+	Method string
+
+	// Campaign the URL is tagged with, to break down statistics by campaign and
+	// enable or disable its URLs together (empty for none)
+	Campaign string
+}
+
+// Methods a URL can be given with the method option
+var entryMethods = []string{http.MethodGet, http.MethodPost}
+
+// parseEntry splits a trimmed URL file line into its URL and options
+func parseEntry(line string) (string, EntryOptions, error) {
+	fields := strings.Fields(line)
+	var options EntryOptions
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return "", options, fmt.Errorf("%w: option %q is not of the form key=value", ErrInvalidEntry, field)
+		}
+		switch key {
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return "", options, fmt.Errorf("%w: timeout %q is not a positive duration", ErrInvalidEntry, value)
+			}
+			options.Timeout = timeout
+		case "method":
+			method := strings.ToUpper(value)
+			if !slices.Contains(entryMethods, method) {
+				return "", options, fmt.Errorf("%w: method %q is not GET or POST", ErrInvalidEntry, value)
+			}
+			options.Method = method
+		case "campaign":
+			if value == "" {
+				return "", options, fmt.Errorf("%w: campaign must not be empty", ErrInvalidEntry)
+			}
+			options.Campaign = value
+		default:
+			return "", options, fmt.Errorf("%w: unknown option %q", ErrInvalidEntry, key)
+		}
+	}
+	return fields[0], options, nil
+}
+
+// entryURL returns the URL of a trimmed, non-empty URL file line without its options
+func entryURL(line string) string {
+	url, _, _ := strings.Cut(line, " ")
+	url, _, _ = strings.Cut(url, "\t")
+	return url
+}
+
+// Options returns the options given to a URL in the URL file, as returned by
+// the manager before placeholders are filled in
+func (m *URLManager) Options(url string) EntryOptions {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.options[url]
+}
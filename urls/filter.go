@@ -3,16 +3,24 @@ package urls
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
-	"sync"
 	"time"
+
+	"fake-traffic-go/fetch"
+	"golang.org/x/sync/errgroup"
 )
 
+// ErrNoValidURLs is returned when filtering a URL file in place would leave no
+// URL in it, unless forced
+var ErrNoValidURLs = errors.New("no valid URLs left")
+
 // FilterOptions configures the URL filtering process
 type FilterOptions struct {
 	// Maximum time to wait when checking URL reachability (in seconds)
@@ -32,6 +40,18 @@ type FilterOptions struct {
 
 	// Protocols to allow (e.g., "https")
 	AllowProtocols []string
+
+	// Whether to sort the output by quality score (see ScoreURLs)
+	SortByScore bool
+
+	// Minimum quality score a URL needs to be kept when sorting by score
+	MinScore float64
+
+	// Longest line accepted in the URL file, in bytes (0 uses the default of 1 MiB)
+	MaxLineLength int
+
+	// Whether to overwrite the input file even when no URL is valid
+	Force bool
 }
 
 // DefaultFilterOptions returns sensible defaults for filtering
@@ -46,53 +66,86 @@ func DefaultFilterOptions() FilterOptions {
 	}
 }
 
+// withDefaults returns a copy of the options with unusable values replaced,
+// so that a zero Workers or Timeout cannot stall or disable filtering
+func (o FilterOptions) withDefaults() FilterOptions {
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultFilterOptions().Timeout
+	}
+	return o
+}
+
 // FilterURLsFile reads, filters, and writes back a list of valid URLs
 func FilterURLsFile(inputPath, outputPath string, options FilterOptions) (int, int, error) {
+	return FilterURLsFileContext(context.Background(), inputPath, outputPath, options)
+}
+
+// FilterURLsFileContext is like FilterURLsFile but stops when the context is
+// cancelled, in which case the output file is left untouched
+func FilterURLsFileContext(ctx context.Context, inputPath, outputPath string, options FilterOptions) (int, int, error) {
 	// Read all URLs from file
-	file, err := os.Open(inputPath)
+	file, err := fetch.Open(inputPath)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer file.Close()
 
+	// Keep every line so comments and layout survive the rewrite
+	var lines []string
 	var urls []string
-	scanner := bufio.NewScanner(file)
+	scanner := newLineScanner(file, options.MaxLineLength)
 	for scanner.Scan() {
-		url := strings.TrimSpace(scanner.Text())
-		if url != "" {
-			urls = append(urls, url)
+		line := scanner.Text()
+		lines = append(lines, line)
+
+		entry := strings.TrimSpace(line)
+		if entry != "" && !isComment(entry) {
+			urls = append(urls, entryURL(entry))
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return 0, 0, fmt.Errorf("error reading input file: %w", err)
+		return 0, 0, fmt.Errorf("error reading input file: %w", scanError(err, inputPath, options.MaxLineLength))
 	}
 
 	totalURLs := len(urls)
+	if totalURLs == 0 {
+		return 0, 0, fmt.Errorf("%w: %s", ErrEmptyURLFile, inputPath)
+	}
 	fmt.Printf("Read %d URLs from %s\n", totalURLs, inputPath)
 
 	// Filter the URLs
-	validURLs, err := FilterURLs(urls, options)
+	validURLs, err := FilterURLsContext(ctx, urls, options)
 	if err != nil {
 		return 0, 0, fmt.Errorf("error filtering URLs: %w", err)
 	}
 
-	// Write filtered URLs back to file
-	outFile, err := os.Create(outputPath)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
+	// Order by quality score if requested
+	if options.SortByScore {
+		scored, err := ScoreURLs(ctx, validURLs, options)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error scoring URLs: %w", err)
+		}
 
-	writer := bufio.NewWriter(outFile)
-	for _, u := range validURLs {
-		if _, err := writer.WriteString(u + "\n"); err != nil {
-			return 0, 0, fmt.Errorf("error writing to output file: %w", err)
+		validURLs = make([]string, 0, len(scored))
+		for _, s := range scored {
+			fmt.Printf("Scored %s: %.2f (status %d, %s, %d redirects)\n",
+				s.URL, s.Score, s.StatusCode, s.Latency.Round(time.Millisecond), s.Redirects)
+			validURLs = append(validURLs, s.URL)
 		}
 	}
 
-	if err := writer.Flush(); err != nil {
-		return 0, 0, fmt.Errorf("error flushing writer: %w", err)
+	// Losing every URL is more likely a network problem than a list gone bad
+	if len(validURLs) == 0 && !options.Force && sameFile(inputPath, outputPath) {
+		return totalURLs, 0, fmt.Errorf("%w: all %d URLs in %s were filtered out, leaving the file unchanged", ErrNoValidURLs, totalURLs, inputPath)
+	}
+
+	// Write filtered URLs back to file
+	if err := writeLinesAtomic(outputPath, outputLines(lines, validURLs, options.SortByScore)); err != nil {
+		return 0, 0, err
 	}
 
 	validCount := len(validURLs)
@@ -102,123 +155,229 @@ func FilterURLsFile(inputPath, outputPath string, options FilterOptions) (int, i
 	return totalURLs, validCount, nil
 }
 
-// FilterURLs processes a slice of URLs and returns only valid ones
+// sameFile reports whether the paths name the same existing local file
+func sameFile(path, other string) bool {
+	if fetch.IsRemote(path) || fetch.IsRemote(other) {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	otherInfo, err := os.Stat(other)
+	return err == nil && os.SameFile(info, otherInfo)
+}
+
+// writeLinesAtomic writes the lines to a temporary file next to path and
+// renames it over path once complete, so a failure never leaves a truncated
+// file behind. This matters when filtering a URL file in place.
+func writeLinesAtomic(path string, lines []string) (err error) {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
+
+	// Keep the permissions of the file being replaced
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmpFile.Chmod(mode); err != nil {
+		return fmt.Errorf("failed to set output file permissions: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmpFile)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("error writing to output file: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error flushing writer: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("error syncing output file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("error closing output file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace output file: %w", err)
+	}
+	return nil
+}
+
+// outputLines builds the filtered file contents. Comments and blank lines are
+// passed through unchanged and valid URLs keep their original position; when
+// sorting by score, comments come first followed by the URLs in score order.
+// URLs keep the options given to them.
+func outputLines(lines []string, validURLs []string, sortByScore bool) []string {
+	var output []string
+
+	if sortByScore {
+		entries := make(map[string]string)
+		for _, line := range lines {
+			trimmed := strings.TrimSpace(line)
+			if isComment(trimmed) {
+				output = append(output, line)
+			} else if trimmed != "" {
+				entries[entryURL(trimmed)] = trimmed
+			}
+		}
+		for _, u := range validURLs {
+			output = append(output, entries[u])
+		}
+		return output
+	}
+
+	valid := make(map[string]bool, len(validURLs))
+	for _, u := range validURLs {
+		valid[u] = true
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || isComment(trimmed):
+			output = append(output, line)
+		case valid[entryURL(trimmed)]:
+			output = append(output, trimmed)
+		}
+	}
+	return output
+}
+
+// FilterURLs processes a slice of URLs and returns only valid ones, in input order
 func FilterURLs(urls []string, options FilterOptions) ([]string, error) {
+	return FilterURLsContext(context.Background(), urls, options)
+}
+
+// FilterURLsContext is like FilterURLs but stops promptly when the context is
+// cancelled. It then returns the URLs found valid so far, in input order,
+// together with the context's error.
+func FilterURLsContext(ctx context.Context, urls []string, options FilterOptions) ([]string, error) {
+	options = options.withDefaults()
+
+	// Create an HTTP client with timeout, shared by all workers
+	client := &http.Client{
+		Timeout: time.Duration(options.Timeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // Don't follow redirects
+		},
+	}
+
+	// Each worker records its verdict at the URL's input position
+	valid := make([]bool, len(urls))
+	var group errgroup.Group
+	group.SetLimit(options.Workers)
+
+	for i := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+
+		index := i
+		group.Go(func() error {
+			ok, reason := checkURL(ctx, client, urls[index], options)
+			if ctx.Err() != nil {
+				// The check was interrupted, so its verdict means nothing
+				return nil
+			}
+			valid[index] = ok
+			if !ok {
+				fmt.Printf("Filtered out %s: %s\n", urls[index], reason)
+			}
+			return nil
+		})
+	}
+
+	// Wait for all workers to finish
+	group.Wait()
+
+	// Reassemble the valid URLs in their original order
 	var validURLs []string
-	var mutex sync.Mutex
-	var wg sync.WaitGroup
-
-	// Create a channel for URLs to process
-	urlChan := make(chan string)
-
-	// Set up workers
-	for i := 0; i < options.Workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-
-			// Create an HTTP client with timeout
-			client := &http.Client{
-				Timeout: time.Duration(options.Timeout) * time.Second,
-				CheckRedirect: func(req *http.Request, via []*http.Request) error {
-					return http.ErrUseLastResponse // Don't follow redirects
-				},
+	for i, u := range urls {
+		if valid[i] {
+			validURLs = append(validURLs, u)
+		}
+	}
+
+	return validURLs, ctx.Err()
+}
+
+// Schemes whose reachability can be checked with an HTTP HEAD request.
+// URLs with other allowed schemes (e.g. ftp) are kept based on syntax alone.
+var reachabilitySchemes = []string{"http", "https"}
+
+// checkURL reports whether a single URL passes the filter and, if not, why
+func checkURL(ctx context.Context, client *http.Client, urlStr string, options FilterOptions) (bool, string) {
+	parsedURL, parseErr := url.Parse(urlStr)
+
+	// Validate URL syntax
+	if options.ValidateURL {
+		if parseErr != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+			return false, "invalid URL format"
+		}
+
+		// Check protocol
+		if len(options.AllowProtocols) > 0 {
+			if !slices.Contains(options.AllowProtocols, parsedURL.Scheme) {
+				return false, "protocol not allowed"
 			}
+		}
 
-			for urlStr := range urlChan {
-				valid := true
-				var reason string
-
-				// Validate URL syntax
-				if options.ValidateURL {
-					parsedURL, err := url.Parse(urlStr)
-					if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-						valid = false
-						reason = "invalid URL format"
-						continue
-					}
-
-					// Check protocol
-					if len(options.AllowProtocols) > 0 {
-						if !slices.Contains(options.AllowProtocols, parsedURL.Scheme) {
-							valid = false
-							reason = "protocol not allowed"
-							continue
-						}
-					}
-
-					// Check excluded domains
-					isDomainExcluded := func(host string, excluded []string) bool {
-						for _, domain := range excluded {
-							if strings.Contains(host, domain) {
-								return true
-							}
-						}
-						return false
-					}
-
-					if isDomainExcluded(parsedURL.Host, options.ExcludeDomains) {
-						valid = false
-						reason = "domain excluded"
-						continue
-					}
-				}
-
-				// Check reachability
-				if valid && options.CheckReachability {
-					ctx, cancel := context.WithTimeout(context.Background(), time.Duration(options.Timeout)*time.Second)
-					req, err := http.NewRequestWithContext(ctx, "HEAD", urlStr, nil)
-					if err != nil {
-						valid = false
-						reason = "failed to create request"
-						cancel()
-						continue
-					}
-
-					// Add a user agent to avoid being blocked
-					req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-
-					resp, err := client.Do(req)
-					cancel()
-
-					if err != nil {
-						valid = false
-						reason = "unreachable"
-						continue
-					}
-
-					resp.Body.Close()
-
-					// Consider non-success status codes as invalid
-					if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-						valid = false
-						reason = fmt.Sprintf("status code %d", resp.StatusCode)
-					}
-				}
-
-				if valid {
-					mutex.Lock()
-					validURLs = append(validURLs, urlStr)
-					mutex.Unlock()
-				} else {
-					fmt.Printf("Filtered out %s: %s\n", urlStr, reason)
-				}
+		// Check excluded domains
+		for _, domain := range options.ExcludeDomains {
+			if strings.Contains(parsedURL.Host, domain) {
+				return false, "domain excluded"
 			}
-		}()
+		}
 	}
 
-	// Send URLs to workers
-	go func() {
-		for _, u := range urls {
-			urlChan <- u
-		}
-		close(urlChan)
-	}()
+	if !options.CheckReachability {
+		return true, ""
+	}
+	if parseErr != nil {
+		return false, "failed to create request"
+	}
 
-	// Wait for all workers to finish
-	wg.Wait()
+	// Reachability can't be checked for non-HTTP schemes
+	if !slices.Contains(reachabilitySchemes, strings.ToLower(parsedURL.Scheme)) {
+		return true, ""
+	}
+
+	// Check reachability
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(options.Timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", urlStr, nil)
+	if err != nil {
+		return false, "failed to create request"
+	}
+
+	// Add a user agent to avoid being blocked
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, "unreachable"
+	}
+	resp.Body.Close()
+
+	// Consider non-success status codes as invalid
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return false, fmt.Sprintf("status code %d", resp.StatusCode)
+	}
 
-	return validURLs, nil
+	return true, ""
 }
 
 // BuildFilterOptions creates a FilterOptions with custom settings
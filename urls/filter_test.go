@@ -0,0 +1,289 @@
+package urls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFilterKeepsAllowedNonHTTPURLsOnSyntax(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	input := []string{
+		server.URL + "/ok",
+		"ftp://files.invalid/pub/data.txt",
+		server.URL + "/missing",
+		"ftp:///no-host",
+		"gopher://gopher.invalid/",
+	}
+	options := DefaultFilterOptions()
+	options.AllowProtocols = []string{"http", "https", "ftp"}
+
+	valid, err := FilterURLs(input, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{server.URL + "/ok", "ftp://files.invalid/pub/data.txt"}
+	if !slices.Equal(valid, want) {
+		t.Fatalf("FilterURLs() = %q, want %q", valid, want)
+	}
+
+	// ftp is dropped again when it's not allowed
+	valid, err = FilterURLs(input, DefaultFilterOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := want[:1]; !slices.Equal(valid, want) {
+		t.Fatalf("FilterURLs() with the default protocols = %q, want %q", valid, want)
+	}
+}
+
+func TestFilterWithoutWorkersOrTimeoutCompletes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	input := []string{server.URL + "/1", "not a url", server.URL + "/2"}
+	for _, workers := range []int{0, -3} {
+		options := DefaultFilterOptions()
+		options.Workers, options.Timeout = workers, 0
+
+		done := make(chan []string, 1)
+		go func() {
+			valid, _ := FilterURLs(input, options)
+			done <- valid
+		}()
+		select {
+		case valid := <-done:
+			if want := []string{input[0], input[2]}; !slices.Equal(valid, want) {
+				t.Errorf("FilterURLs() with %d workers = %q, want %q", workers, valid, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("FilterURLs() with %d workers did not complete", workers)
+		}
+	}
+}
+
+func TestFilterKeepsInputOrder(t *testing.T) {
+	// Earlier URLs answer later, so completion order is the reverse of input order
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		time.Sleep(time.Duration(20-index) * 5 * time.Millisecond)
+		if index%3 == 0 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var input, want []string
+	for i := 0; i < 20; i++ {
+		url := fmt.Sprintf("%s/%d", server.URL, i)
+		input = append(input, url)
+		if i%3 != 0 {
+			want = append(want, url)
+		}
+	}
+	options := DefaultFilterOptions()
+	options.Workers = 20
+
+	for run := 0; run < 3; run++ {
+		valid, err := FilterURLs(input, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(valid, want) {
+			t.Fatalf("run %d: FilterURLs() = %q, want %q", run, valid, want)
+		}
+	}
+}
+
+func TestFilterCancelledMidRunReturnsPromptly(t *testing.T) {
+	slowStarted := make(chan struct{}, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/slow") {
+			slowStarted <- struct{}{}
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	input := []string{server.URL + "/fast/1", server.URL + "/fast/2"}
+	for i := 0; i < 50; i++ {
+		input = append(input, fmt.Sprintf("%s/slow/%d", server.URL, i))
+	}
+	options := DefaultFilterOptions()
+	options.Workers, options.Timeout = 2, 60
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type outcome struct {
+		valid []string
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		valid, err := FilterURLsContext(ctx, input, options)
+		done <- outcome{valid, err}
+	}()
+
+	<-slowStarted
+	cancel()
+	select {
+	case result := <-done:
+		if !errors.Is(result.err, context.Canceled) {
+			t.Errorf("FilterURLsContext() error = %v, want context.Canceled", result.err)
+		}
+		// Only URLs checked before cancelling are kept, none of the interrupted ones
+		for _, url := range result.valid {
+			if !strings.Contains(url, "/fast/") {
+				t.Errorf("FilterURLsContext() kept %s, whose check was interrupted", url)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("FilterURLsContext() did not return after cancelling")
+	}
+}
+
+func TestInterruptedFilterLeavesFileIntact(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	lines := []string{"# pages", server.URL + "/fast", "gopher://gone.invalid/", server.URL + "/slow"}
+	path := writeURLFile(t, lines...)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The slow URL hangs until the deadline interrupts the filter
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, _, err := FilterURLsFileContext(ctx, path, path, DefaultFilterOptions()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FilterURLsFileContext() error = %v, want the context's", err)
+	}
+	if contents, _ := os.ReadFile(path); !bytes.Equal(contents, original) {
+		t.Errorf("URL file after an interrupted filter:\n%s\nwant it unchanged:\n%s", contents, original)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want only the URL file", len(entries))
+	}
+}
+
+func TestFilterInterruptedWhileScoringLeavesFileIntact(t *testing.T) {
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The filter's check passes, the scoring probe after it hangs
+		if probes.Add(1) > 1 {
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	path := writeURLFile(t, server.URL+"/page")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultFilterOptions()
+	options.SortByScore, options.Timeout = true, 60
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := FilterURLsFileContext(ctx, path, path, options); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FilterURLsFileContext() error = %v, want the context's", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("FilterURLsFileContext() took %s to return after the deadline", elapsed)
+	}
+	if contents, _ := os.ReadFile(path); !bytes.Equal(contents, original) {
+		t.Errorf("URL file after an interrupted scoring:\n%s\nwant it unchanged:\n%s", contents, original)
+	}
+}
+
+func TestFailedWriteLeavesNoTemporaryFile(t *testing.T) {
+	dir := t.TempDir()
+	// A file can't be renamed over a non-empty directory
+	target := filepath.Join(dir, "urls.txt")
+	if err := os.MkdirAll(filepath.Join(target, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeLinesAtomic(target, []string{"https://a.example/"}); err == nil {
+		t.Fatal("writeLinesAtomic() over a directory succeeded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d entries left in the directory, want the temporary file removed", len(entries))
+	}
+}
+
+func TestFilterFileKeepsVeryLongLines(t *testing.T) {
+	long := "https://a.example/?token=" + strings.Repeat("x", 200*1024)
+	path := writeURLFile(t, long, "not a url")
+	options := DefaultFilterOptions()
+	options.CheckReachability = false
+
+	if _, valid, err := FilterURLsFile(path, path, options); err != nil || valid != 1 {
+		t.Fatalf("FilterURLsFile() = %d valid URLs, error %v, want the long URL kept", valid, err)
+	}
+	if contents, _ := os.ReadFile(path); string(contents) != long+"\n" {
+		t.Errorf("filtered file is %d bytes, want the long URL alone", len(contents))
+	}
+}
+
+func TestFilterRefusesToEmptyTheFileInPlace(t *testing.T) {
+	lines := []string{"# nothing valid", "not a url", "gopher://gone.invalid/"}
+	path := writeURLFile(t, lines...)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultFilterOptions()
+	options.CheckReachability = false
+
+	if _, _, err := FilterURLsFile(path, path, options); !errors.Is(err, ErrNoValidURLs) {
+		t.Fatalf("FilterURLsFile() error = %v, want ErrNoValidURLs", err)
+	}
+	if contents, _ := os.ReadFile(path); !bytes.Equal(contents, original) {
+		t.Errorf("URL file after filtering out every URL:\n%s\nwant it unchanged", contents)
+	}
+
+	// Writing elsewhere can't lose the URLs, and forcing overwrites in place
+	output := filepath.Join(t.TempDir(), "filtered.txt")
+	if _, valid, err := FilterURLsFile(path, output, options); err != nil || valid != 0 {
+		t.Errorf("FilterURLsFile() to another file = %d valid URLs, error %v", valid, err)
+	}
+	options.Force = true
+	if _, _, err := FilterURLsFile(path, path, options); err != nil {
+		t.Fatalf("forced FilterURLsFile() error = %v", err)
+	}
+	if contents, _ := os.ReadFile(path); string(contents) != "# nothing valid\n" {
+		t.Errorf("URL file after a forced filter = %q, want only the comment", contents)
+	}
+}
+
+func TestFilterEmptyFileReturnsErrEmptyURLFile(t *testing.T) {
+	path := writeURLFile(t, "# nothing to filter")
+	if _, _, err := FilterURLsFile(path, path, DefaultFilterOptions()); !errors.Is(err, ErrEmptyURLFile) {
+		t.Errorf("FilterURLsFile() error = %v, want ErrEmptyURLFile", err)
+	}
+}
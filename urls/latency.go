@@ -0,0 +1,125 @@
+package urls
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of random candidates weighed against each other per selection
+	latencyCandidates = 4
+
+	// Weight of the newest observation in a URL's moving average latency
+	latencySmoothing = 0.3
+)
+
+// LatencySelector picks URLs with a bias based on their recently observed
+// latency. A positive bias prefers slow URLs, stressing them further, while a
+// negative bias avoids them. Candidates are drawn from the URL manager, so its
+// shard and host budget still apply.
+type LatencySelector struct {
+	manager   *URLManager
+	bias      float64
+	latencies map[string]float64 // Moving average latency in seconds, by URL file entry
+	mu        sync.Mutex
+	rand      *rand.Rand
+}
+
+// NewLatencySelector creates a selector drawing URLs from the manager
+func NewLatencySelector(manager *URLManager, bias float64) *LatencySelector {
+	return &LatencySelector{
+		manager:   manager,
+		bias:      bias,
+		latencies: make(map[string]float64),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Observe records the latency of a request to the URL of the URL file entry.
+// For a URL template, entry is the template rather than the URL expanded from it.
+func (s *LatencySelector) Observe(entry string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seconds := latency.Seconds()
+	if average, ok := s.latencies[entry]; ok {
+		seconds = average + latencySmoothing*(seconds-average)
+	}
+	s.latencies[entry] = seconds
+}
+
+// Next implements URLSelector. It draws a few random candidates and picks one
+// with a probability weighted by its latency relative to the others.
+func (s *LatencySelector) Next(prev string) string {
+	return s.pick(prev, s.manager.Next, s.rand)
+}
+
+// WithRand returns a selector picking URLs like s, sharing its observations,
+// but drawing the candidates and the pick from r.
+// r must not be used concurrently.
+func (s *LatencySelector) WithRand(r *rand.Rand) URLSelector {
+	return seededLatencySelector{selector: s, rand: r}
+}
+
+// seededLatencySelector picks URLs by latency with a random source of its own
+type seededLatencySelector struct {
+	selector *LatencySelector
+	rand     *rand.Rand
+}
+
+// Next implements URLSelector
+func (s seededLatencySelector) Next(prev string) string {
+	candidates := seededSelector{manager: s.selector.manager, rand: s.rand}
+	return s.selector.pick(prev, candidates.Next, s.rand)
+}
+
+// pick draws the candidates with draw and picks one of them with r, which is
+// only used while holding the mutex
+func (s *LatencySelector) pick(prev string, draw func(string) string, r *rand.Rand) string {
+	var candidates [latencyCandidates]string
+	for i := range candidates {
+		candidates[i] = draw(prev)
+		if candidates[i] == "" {
+			return ""
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// URLs without observations count as average
+	var latencies [latencyCandidates]float64
+	sum, observed := 0.0, 0
+	for i, url := range candidates {
+		if latency, ok := s.latencies[url]; ok && latency > 0 {
+			latencies[i] = latency
+			sum += latency
+			observed++
+		}
+	}
+	if observed == 0 {
+		return candidates[0]
+	}
+	mean := sum / float64(observed)
+
+	var weights [latencyCandidates]float64
+	total := 0.0
+	for i := range candidates {
+		if latencies[i] == 0 {
+			latencies[i] = mean
+		}
+		weights[i] = math.Pow(latencies[i]/mean, s.bias)
+		total += weights[i]
+	}
+
+	point := r.Float64() * total
+	for i, weight := range weights {
+		point -= weight
+		if point < 0 {
+			return candidates[i]
+		}
+	}
+	return candidates[len(candidates)-1]
+}
@@ -0,0 +1,51 @@
+package urls
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestLatencySelectorWithRandIsReproducible(t *testing.T) {
+	m := loadURLs(t, "https://a.example/", "https://b.example/", "https://c.example/", "https://d.example/")
+	s := NewLatencySelector(m, 1)
+	s.Observe("https://a.example/", 10*time.Millisecond)
+	s.Observe("https://b.example/", 200*time.Millisecond)
+
+	sequence := func(seed int64) []string {
+		selector := s.WithRand(rand.New(rand.NewSource(seed)))
+		urls := make([]string, 50)
+		for i := range urls {
+			urls[i] = selector.Next("")
+		}
+		return urls
+	}
+	first, second := sequence(7), sequence(7)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("selection %d is %q then %q from the same seed", i, first[i], second[i])
+		}
+	}
+}
+
+func TestLatencySelectorWeighsTemplateEntries(t *testing.T) {
+	const template = "https://slow.example/items/{id:1-1000}"
+	m := loadURLs(t, template, "https://fast.example/")
+	s := NewLatencySelector(m, 4)
+
+	// Observations are made for the entry, whichever URL it expanded to
+	s.Observe(template, time.Second)
+	s.Observe("https://fast.example/", 10*time.Millisecond)
+
+	selector := s.WithRand(rand.New(rand.NewSource(1)))
+	slow := 0
+	for i := 0; i < 1000; i++ {
+		if selector.Next("") == template {
+			slow++
+		}
+	}
+	// Without the bias the template would be picked about half of the time
+	if slow < 900 {
+		t.Fatalf("slow template picked %d times out of 1000, want nearly always", slow)
+	}
+}
@@ -0,0 +1,34 @@
+package urls
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Longest line accepted in a URL file when no limit is configured
+const defaultMaxLineLength = 1024 * 1024
+
+// newLineScanner returns a scanner reading lines of up to maxLength bytes
+// (0 uses the default), allowing for URLs with very long query strings
+func newLineScanner(r io.Reader, maxLength int) *bufio.Scanner {
+	if maxLength <= 0 {
+		maxLength = defaultMaxLineLength
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxLength)), maxLength)
+	return scanner
+}
+
+// scanError explains a scanner error, pointing out how to accept longer lines
+func scanError(err error, filePath string, maxLength int) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		if maxLength <= 0 {
+			maxLength = defaultMaxLineLength
+		}
+		return fmt.Errorf("%s contains a line longer than %d bytes, raise the maximum URL length: %w", filePath, maxLength, err)
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package urls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Maximum number of redirects followed while scoring a URL
+const maxScoreRedirects = 5
+
+// ScoredURL holds the measurements and quality score for a single URL
+type ScoredURL struct {
+	URL        string
+	Score      float64
+	StatusCode int
+	Latency    time.Duration
+	Redirects  int
+	Err        error
+}
+
+// ScoreURLs measures each URL and returns them sorted by descending score.
+// The score is in the range [0, 1] and rewards fast responses with a success
+// status, while penalising redirects. URLs scoring below options.MinScore are dropped.
+// When the context is cancelled the probes in flight are interrupted and the
+// URLs not probed yet are skipped, and the context's error is returned.
+func ScoreURLs(ctx context.Context, urls []string, options FilterOptions) ([]ScoredURL, error) {
+	options = options.withDefaults()
+
+	scored := make([]ScoredURL, len(urls))
+	var wg sync.WaitGroup
+
+	// Create a channel of indexes to process so results keep their input position
+	indexChan := make(chan int)
+
+	for i := 0; i < options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			redirects := 0
+			client := &http.Client{
+				Timeout: time.Duration(options.Timeout) * time.Second,
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					redirects = len(via)
+					if len(via) >= maxScoreRedirects {
+						return http.ErrUseLastResponse
+					}
+					return nil
+				},
+			}
+
+			for index := range indexChan {
+				redirects = 0
+				scored[index] = scoreURL(ctx, client, urls[index], options)
+				scored[index].Redirects = redirects
+				scored[index].Score = computeScore(scored[index])
+			}
+		}()
+	}
+
+	go func() {
+		defer close(indexChan)
+		for i := range urls {
+			select {
+			case indexChan <- i:
+			case <-ctx.Done():
+				// Leave the remaining URLs unprobed, they score zero
+				for ; i < len(urls); i++ {
+					scored[i] = ScoredURL{URL: urls[i], Err: ctx.Err()}
+				}
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	// Stable sort keeps the input order for URLs with equal scores
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+
+	// Drop URLs below the threshold
+	kept := scored[:0]
+	for _, s := range scored {
+		if s.Score >= options.MinScore {
+			kept = append(kept, s)
+		}
+	}
+
+	return kept, ctx.Err()
+}
+
+// scoreURL makes a single HEAD request and records the status and latency
+func scoreURL(ctx context.Context, client *http.Client, urlStr string, options FilterOptions) ScoredURL {
+	result := ScoredURL{URL: urlStr}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(options.Timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", urlStr, nil)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to create request: %w", err)
+		return result
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	return result
+}
+
+// computeScore combines status, latency and redirect count into a single score
+func computeScore(s ScoredURL) float64 {
+	if s.Err != nil {
+		return 0
+	}
+
+	var statusScore float64
+	switch {
+	case s.StatusCode >= 200 && s.StatusCode < 300:
+		statusScore = 1.0
+	case s.StatusCode >= 300 && s.StatusCode < 400:
+		statusScore = 0.6
+	case s.StatusCode >= 400 && s.StatusCode < 500:
+		statusScore = 0.2
+	default:
+		statusScore = 0.1
+	}
+
+	// Halves the score at one second of latency and keeps decreasing after that
+	latencyScore := 1.0 / (1.0 + s.Latency.Seconds())
+
+	score := statusScore*latencyScore - 0.1*float64(s.Redirects)
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
@@ -0,0 +1,73 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScoreURLsRanksFastSuccessFirst(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	options := DefaultFilterOptions()
+	options.Workers = 3
+	scored, err := ScoreURLs(context.Background(), []string{server.URL + "/missing", server.URL + "/slow", server.URL + "/fast"}, options)
+	if err != nil {
+		t.Fatalf("ScoreURLs() error = %v", err)
+	}
+	if len(scored) != 3 {
+		t.Fatalf("ScoreURLs() returned %d URLs, want 3", len(scored))
+	}
+
+	want := []string{server.URL + "/fast", server.URL + "/slow", server.URL + "/missing"}
+	for i, s := range scored {
+		if s.URL != want[i] {
+			t.Errorf("ScoreURLs()[%d] = %s (score %.3f), want %s", i, s.URL, s.Score, want[i])
+		}
+	}
+	if scored[0].StatusCode != http.StatusOK {
+		t.Errorf("fast URL status = %d, want %d", scored[0].StatusCode, http.StatusOK)
+	}
+}
+
+func TestScoreURLsStopsWhenCancelled(t *testing.T) {
+	started := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	options := DefaultFilterOptions()
+	options.Workers, options.Timeout = 1, 60
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := ScoreURLs(ctx, []string{server.URL + "/1", server.URL + "/2", server.URL + "/3"}, options)
+		done <- err
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ScoreURLs() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ScoreURLs() did not return after cancelling")
+	}
+}
@@ -0,0 +1,47 @@
+package urls
+
+import "math/rand"
+
+// URLSelector picks the next URL a user navigates to
+type URLSelector interface {
+	// Next returns the URL to visit after prev.
+	// prev is empty for the first request of a session.
+	// An empty result means no URL is currently selectable.
+	Next(prev string) string
+}
+
+// SeedableSelector is a URLSelector that can draw its random choices from a
+// source of the caller's, such as a user's seeded source
+type SeedableSelector interface {
+	URLSelector
+
+	// WithRand returns a selector choosing like this one but drawing from r.
+	// r must not be used concurrently.
+	WithRand(r *rand.Rand) URLSelector
+}
+
+// Next implements URLSelector by picking a random URL, independent of the previous one
+func (m *URLManager) Next(prev string) string {
+	return m.GetRandomURL()
+}
+
+// WithRand returns a selector picking random URLs of m like m itself, but drawn
+// from r, so that the sequence of URLs is reproducible for a seeded r.
+// r must not be used concurrently.
+func (m *URLManager) WithRand(r *rand.Rand) URLSelector {
+	return seededSelector{manager: m, rand: r}
+}
+
+// seededSelector picks random URLs with a random source of its own
+type seededSelector struct {
+	manager *URLManager
+	rand    *rand.Rand
+}
+
+// Next implements URLSelector. Only the read lock is needed since the random
+// source belongs to the selector.
+func (s seededSelector) Next(prev string) string {
+	s.manager.mu.RLock()
+	defer s.manager.mu.RUnlock()
+	return s.manager.pick(s.rand)
+}
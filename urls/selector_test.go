@@ -0,0 +1,41 @@
+package urls
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// TestSeededSelectorsConcurrently selects from many seeded selectors sharing
+// one manager while host requests are recorded; run with -race.
+func TestSeededSelectorsConcurrently(t *testing.T) {
+	m := loadURLs(t, "https://a.example/", "https://b.example/", "https://c.example/")
+	m.SetHostBudget(1 << 30)
+
+	const users, selections = 16, 500
+	sequences := make([][]string, users)
+	var wg sync.WaitGroup
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Users with the same seed share a sequence
+			selector := m.WithRand(rand.New(rand.NewSource(int64(i % 2))))
+			for j := 0; j < selections; j++ {
+				url := selector.Next("")
+				m.RecordHostRequest(url)
+				sequences[i] = append(sequences[i], url)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 2; i < users; i++ {
+		want := sequences[i%2]
+		for j, url := range sequences[i] {
+			if url != want[j] {
+				t.Fatalf("user %d selected %q at %d, want %q as drawn from the same seed", i, url, j, want[j])
+			}
+		}
+	}
+}
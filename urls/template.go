@@ -0,0 +1,107 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidTemplate is returned for URL templates that cannot be parsed
+var ErrInvalidTemplate = errors.New("invalid URL template")
+
+// URLTemplate is a URL with placeholders such as /users/{id}/orders/{oid}
+// that are filled in with a fresh value for every request. A placeholder's
+// values are given inline as {name:spec} or by name in the template parameters,
+// where spec is either a numeric range "1-1000" or a list of values "a|b|c".
+type URLTemplate struct {
+	parts []templatePart
+}
+
+// templatePart is a literal piece of a template or a placeholder
+type templatePart struct {
+	literal string
+	param   *templateParam
+}
+
+// templateParam describes the values a placeholder expands to
+type templateParam struct {
+	min, max int
+	values   []string // Used instead of the range when set
+}
+
+// IsTemplate reports whether a URL contains placeholders
+func IsTemplate(rawURL string) bool {
+	return strings.Contains(rawURL, "{")
+}
+
+// ParseURLTemplate parses a URL template. params maps placeholder names to
+// specs for placeholders that don't have an inline spec.
+func ParseURLTemplate(rawURL string, params map[string]string) (*URLTemplate, error) {
+	template := &URLTemplate{}
+	rest := rawURL
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			template.parts = append(template.parts, templatePart{literal: rest})
+			return template, nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: %s: unclosed placeholder", ErrInvalidTemplate, rawURL)
+		}
+		end += start
+
+		name, spec, inline := strings.Cut(rest[start+1:end], ":")
+		if !inline {
+			var ok bool
+			if spec, ok = params[name]; !ok {
+				return nil, fmt.Errorf("%w: %s: no values for placeholder %q", ErrInvalidTemplate, rawURL, name)
+			}
+		}
+
+		param, err := parseTemplateParam(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: placeholder %q: %w", ErrInvalidTemplate, rawURL, name, err)
+		}
+
+		template.parts = append(template.parts, templatePart{literal: rest[:start]}, templatePart{param: param})
+		rest = rest[end+1:]
+	}
+}
+
+// parseTemplateParam parses a numeric range "min-max" or a value list "a|b|c"
+func parseTemplateParam(spec string) (*templateParam, error) {
+	if low, high, ok := strings.Cut(spec, "-"); ok {
+		first, firstErr := strconv.Atoi(low)
+		last, lastErr := strconv.Atoi(high)
+		if firstErr == nil && lastErr == nil {
+			if last < first {
+				return nil, fmt.Errorf("range %s is empty", spec)
+			}
+			return &templateParam{min: first, max: last}, nil
+		}
+	}
+
+	if spec == "" {
+		return nil, errors.New("no values")
+	}
+	return &templateParam{values: strings.Split(spec, "|")}, nil
+}
+
+// Expand returns the URL with every placeholder replaced by a random value
+func (t *URLTemplate) Expand(r *rand.Rand) string {
+	var b strings.Builder
+	for _, part := range t.parts {
+		switch {
+		case part.param == nil:
+			b.WriteString(part.literal)
+		case part.param.values != nil:
+			b.WriteString(part.param.values[r.Intn(len(part.param.values))])
+		default:
+			b.WriteString(strconv.Itoa(part.param.min + r.Intn(part.param.max-part.param.min+1)))
+		}
+	}
+	return b.String()
+}
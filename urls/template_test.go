@@ -0,0 +1,52 @@
+package urls
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestURLTemplateExpandsToValidVaryingValues(t *testing.T) {
+	template, err := ParseURLTemplate("https://api.example/users/{id:1-50}/orders/{oid}?sort={order}",
+		map[string]string{"oid": "100-200", "order": "asc|desc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expanded := regexp.MustCompile(`^https://api\.example/users/(\d+)/orders/(\d+)\?sort=(\w+)$`)
+	ids := make(map[int]bool)
+	orders := make(map[string]bool)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		url := template.Expand(r)
+		match := expanded.FindStringSubmatch(url)
+		if match == nil {
+			t.Fatalf("Expand() = %q, want every placeholder filled in", url)
+		}
+		id, _ := strconv.Atoi(match[1])
+		oid, _ := strconv.Atoi(match[2])
+		if id < 1 || id > 50 || oid < 100 || oid > 200 || (match[3] != "asc" && match[3] != "desc") {
+			t.Errorf("Expand() = %q, want values from the placeholders' ranges and lists", url)
+		}
+		ids[id] = true
+		orders[match[3]] = true
+	}
+	if len(ids) < 30 || len(orders) != 2 {
+		t.Errorf("200 expansions used %d ids and %d orders, want the values to vary", len(ids), len(orders))
+	}
+}
+
+func TestParseURLTemplateRejectsInvalidTemplates(t *testing.T) {
+	for _, rawURL := range []string{
+		"https://api.example/users/{id",        // Unclosed
+		"https://api.example/users/{id}",       // No values
+		"https://api.example/users/{id:50-1}",  // Empty range
+		"https://api.example/users/{id:}/info", // Empty list
+	} {
+		if _, err := ParseURLTemplate(rawURL, nil); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("ParseURLTemplate(%q) error = %v, want ErrInvalidTemplate", rawURL, err)
+		}
+	}
+}
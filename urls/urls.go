@@ -2,66 +2,184 @@ package urls
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"fake-traffic-go/fetch"
+)
+
+var (
+	// ErrEmptyURLFile is returned when a URL file contains no usable URLs
+	ErrEmptyURLFile = errors.New("URL file contains no URLs")
+
+	// ErrInvalidShard is returned for a shard index outside the shard count
+	ErrInvalidShard = errors.New("invalid shard")
 )
 
 // URLManager manages a list of URLs to be used for traffic generation
 type URLManager struct {
-	urls []string
-	mu   sync.RWMutex
-	rand *rand.Rand
+	urls       []string
+	hosts      []string        // Host of each URL, by index
+	campaigns  []string        // Campaign of each URL, by index, empty for none
+	disabled   map[string]bool // Campaigns whose URLs are not selected
+	shardIndex int
+	shardCount int
+	hostBudget atomic.Int64 // Read without the mutex on every request
+	maxLineLen int
+	hostCounts sync.Map                // Requests recorded per host, as *atomic.Int64
+	options    map[string]EntryOptions // Options of the URLs given any
+	allowlist  *targetAllowlist        // nil allows every target
+	mu         sync.RWMutex
+	rand       *rand.Rand
 }
 
 // NewURLManager creates a new URL manager
 func NewURLManager() *URLManager {
 	source := rand.NewSource(time.Now().UnixNano())
 	return &URLManager{
-		urls: make([]string, 0),
-		rand: rand.New(source),
+		urls:     make([]string, 0),
+		disabled: make(map[string]bool),
+		rand:     rand.New(source),
+	}
+}
+
+// SetShard restricts LoadFromFile to the URLs belonging to one shard of the list.
+// A URL belongs to shard hash(url) % count, so instances configured with the same
+// count and distinct indexes handle disjoint parts of the same file.
+// A count of 0 or 1 disables sharding.
+func (m *URLManager) SetShard(index, count int) error {
+	if count < 0 || index < 0 || (count > 0 && index >= count) {
+		return fmt.Errorf("%w: %d of %d", ErrInvalidShard, index, count)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shardIndex = index
+	m.shardCount = count
+	return nil
+}
+
+// SetMaxLineLength sets the longest line LoadFromFile accepts, in bytes.
+// A length of 0 uses the default of 1 MiB.
+func (m *URLManager) SetMaxLineLength(length int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxLineLen = length
+}
+
+// inShard reports whether the URL belongs to the given shard
+func inShard(url string, index, count int) bool {
+	if count <= 1 {
+		return true
 	}
+
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	return int(h.Sum32()%uint32(count)) == index
+}
+
+// isComment reports whether a trimmed line from a URL file is a comment
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "#")
 }
 
-// LoadFromFile reads URLs from a file (one URL per line)
+// LoadFromFile reads URLs from a file (one URL per line), given as a local path
+// or as an http(s) URL to fetch it from.
+// Blank lines and lines starting with '#' are ignored.
+// A URL may be followed by options, see EntryOptions.
+// A URL outside the allowlist, if one is set, fails the whole file.
 func (m *URLManager) LoadFromFile(filePath string) error {
-	file, err := os.Open(filePath)
+	file, err := fetch.Open(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	m.mu.RLock()
+	shardIndex, shardCount, maxLineLen := m.shardIndex, m.shardCount, m.maxLineLen
+	allowlist := m.allowlist
+	m.mu.RUnlock()
+
 	var urls []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		url := scanner.Text()
-		if url != "" {
+	options := make(map[string]EntryOptions)
+	scanner := newLineScanner(file, maxLineLen)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || isComment(entry) {
+			continue
+		}
+		url, entryOptions, err := parseEntry(entry)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", filePath, line, err)
+		}
+		// Checked before sharding, so every instance refuses the same file
+		if !allowlist.allows(url) {
+			return fmt.Errorf("%w: %s line %d: %s", ErrTargetNotAllowed, filePath, line, url)
+		}
+		if inShard(url, shardIndex, shardCount) {
 			urls = append(urls, url)
+			if entryOptions != (EntryOptions{}) {
+				options[url] = entryOptions
+			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return err
+		return scanError(err, filePath, maxLineLen)
+	}
+
+	if len(urls) == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyURLFile, filePath)
+	}
+
+	hosts := make([]string, len(urls))
+	campaigns := make([]string, len(urls))
+	for i, u := range urls {
+		hosts[i] = hostOf(u)
+		campaigns[i] = options[u].Campaign
 	}
 
 	m.mu.Lock()
 	m.urls = urls
+	m.hosts = hosts
+	m.campaigns = campaigns
+	m.options = options
 	m.mu.Unlock()
 
 	return nil
 }
 
-// GetRandomURL returns a random URL from the loaded list
+// GetRandomURL returns a random URL from the loaded list, or an empty string
+// if no URL is loaded or every URL's host has used up its budget or campaign
+// is disabled
 func (m *URLManager) GetRandomURL() string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	// A full lock is required since the random source is not safe for concurrent use
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pick(m.rand)
+}
 
+// pick returns a random URL drawn with r; the caller must hold the mutex,
+// at least for reading
+func (m *URLManager) pick(r *rand.Rand) string {
 	if len(m.urls) == 0 {
-		return "https://example.com"
+		return ""
 	}
 
-	index := m.rand.Intn(len(m.urls))
+	index := r.Intn(len(m.urls))
+	if !m.selectable(index) {
+		var ok bool
+		if index, ok = m.randomSelectable(r); !ok {
+			return ""
+		}
+	}
 	return m.urls[index]
 }
 
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Time allowed for a graceful shutdown once the max lifetime is reached
+const watchdogGracePeriod = 10 * time.Second
+
+// startWatchdog arms a timer that calls stop once lifetime has elapsed.
+// If stop has not returned within the grace period, exit is called with a
+// non-zero status so a hung shutdown cannot keep the process alive.
+// The returned timer can be stopped to disarm the watchdog.
+func startWatchdog(lifetime, grace time.Duration, stop func(), exit func(int)) *time.Timer {
+	return time.AfterFunc(lifetime, func() {
+		fmt.Printf("\nMax lifetime of %s reached, shutting down\n", lifetime)
+
+		done := make(chan struct{})
+		go func() {
+			stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			exit(exitOK)
+		case <-time.After(grace):
+			fmt.Printf("Shutdown did not complete within %s, forcing exit\n", grace)
+			exit(exitFailure)
+		}
+	})
+}
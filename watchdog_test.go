@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fake-traffic-go/config"
+	"fake-traffic-go/internal"
+)
+
+// newTestConfig returns a configuration with one user requesting the URL
+func newTestConfig(t *testing.T, url string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte(url+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.URLFilePath = path
+	cfg.ConcurrentUsers = 1
+	return cfg
+}
+
+// startGenerator starts a generator with the configuration, stopped at the end of the test
+func startGenerator(t *testing.T, cfg *config.Config) *internal.TrafficGenerator {
+	t.Helper()
+	generator, err := internal.NewTrafficGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generator.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(generator.Stop)
+	return generator
+}
+
+// startTestGenerator starts a generator with one user requesting the server
+// and waits for its first successful request
+func startTestGenerator(t *testing.T, server *httptest.Server) *internal.TrafficGenerator {
+	t.Helper()
+	generator := startGenerator(t, newTestConfig(t, server.URL+"/"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for generator.GetStatsSnapshot().TotalRequests == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no request made within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return generator
+}
+
+func TestWatchdogShutdownPrintsHistogram(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	generator := startTestGenerator(t, server)
+
+	var output strings.Builder
+	shutdown := &shutdown{generator: generator, histogram: true, output: &output}
+	exitCodes := make(chan int, 1)
+	startWatchdog(10*time.Millisecond, 5*time.Second, shutdown.run, func(code int) { exitCodes <- code })
+
+	select {
+	case code := <-exitCodes:
+		if code != exitOK {
+			t.Errorf("exit code = %d, want %d", code, exitOK)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("watchdog did not exit")
+	}
+	if !strings.Contains(output.String(), "Response time histogram:") || strings.Contains(output.String(), "no successful requests") {
+		t.Errorf("histogram printed before exiting:\n%s", output.String())
+	}
+
+	// Any later way out of the run finds it already shut down
+	shutdown.run()
+	if strings.Count(output.String(), "Response time histogram:") != 1 {
+		t.Errorf("histogram printed more than once:\n%s", output.String())
+	}
+}
+
+// waitExit returns the exit code passed to the watchdog's exit function, or
+// -1 if it is not called within the timeout
+func waitExit(exitCodes <-chan int, timeout time.Duration) int {
+	select {
+	case code := <-exitCodes:
+		return code
+	case <-time.After(timeout):
+		return -1
+	}
+}
+
+func TestWatchdogStopsAfterLifetime(t *testing.T) {
+	stopped := make(chan time.Time, 1)
+	exitCodes := make(chan int, 1)
+	start := time.Now()
+	startWatchdog(100*time.Millisecond, time.Second, func() { stopped <- time.Now() }, func(code int) { exitCodes <- code })
+
+	if code := waitExit(exitCodes, 5*time.Second); code != exitOK {
+		t.Fatalf("exit code = %d, want %d after a clean stop", code, exitOK)
+	}
+	if elapsed := (<-stopped).Sub(start); elapsed < 100*time.Millisecond {
+		t.Errorf("stopped after %s, before the lifetime", elapsed)
+	}
+}
+
+func TestWatchdogForcesExitWhenStopHangs(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	exitCodes := make(chan int, 1)
+	start := time.Now()
+	startWatchdog(10*time.Millisecond, 200*time.Millisecond, func() { <-hang }, func(code int) { exitCodes <- code })
+
+	if code := waitExit(exitCodes, 5*time.Second); code != exitFailure {
+		t.Fatalf("exit code = %d, want %d when stop hangs", code, exitFailure)
+	}
+	if elapsed := time.Since(start); elapsed < 210*time.Millisecond {
+		t.Errorf("forced exit after %s, before the grace period", elapsed)
+	}
+}
+
+func TestWatchdogDisarmed(t *testing.T) {
+	exitCodes := make(chan int, 1)
+	watchdog := startWatchdog(50*time.Millisecond, time.Second, func() {}, func(code int) { exitCodes <- code })
+	if !watchdog.Stop() {
+		t.Fatal("watchdog fired before being disarmed")
+	}
+	if code := waitExit(exitCodes, 200*time.Millisecond); code != -1 {
+		t.Errorf("disarmed watchdog exited with %d", code)
+	}
+}